@@ -0,0 +1,231 @@
+package calendar
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+/*
+Trading calendars (see [Simulation] calendar):
+
+	crypto: open 24/7 (default)
+	equity: open on weekdays between session_start and session_end in the configured timezone,
+	        closed on NYSE holidays and the configured holidays
+
+Sessions are in local time so DST changes move them with the exchange's clock. A bar is open if any part of
+[t, t+interval) overlaps a session, a daily bar stamped at midnight or an hourly bar stamped 09:00 is open.
+*/
+
+var Calendars = []string{"crypto", "equity"}
+
+const TimeFormat = "2006-01-02 15:04:05 MST"
+
+const secondsPerYear = 365 * 24 * 60 * 60
+
+// Trading days in a year of an equity calendar (weekdays less the exchange holidays)
+const TradingDaysPerYear = 252
+
+type Calendar interface {
+	IsOpen(t int64, intervalSeconds int64) bool
+}
+
+type Crypto struct{}
+
+type Equity struct {
+	Location     *time.Location
+	SessionStart time.Duration   // Time of day the session opens
+	SessionEnd   time.Duration   // Time of day the session closes (exclusive)
+	Holidays     map[string]bool // Extra closed dates (2006-01-02)
+}
+
+func (Crypto) IsOpen(t int64, intervalSeconds int64) bool {
+	return true
+}
+
+func (e Equity) IsOpen(t int64, intervalSeconds int64) bool {
+	if intervalSeconds < 1 {
+		intervalSeconds = 1
+	}
+	barStart := time.Unix(t, 0).In(e.Location)
+	barEnd := barStart.Add(time.Duration(intervalSeconds) * time.Second)
+	day := time.Date(barStart.Year(), barStart.Month(), barStart.Day(), 0, 0, 0, 0, e.Location)
+	for ; day.Before(barEnd); day = day.AddDate(0, 0, 1) {
+		if !e.isTradingDay(day) {
+			continue
+		}
+		open := e.timeOfDay(day, e.SessionStart)
+		close := e.timeOfDay(day, e.SessionEnd)
+		if barStart.Before(close) && open.Before(barEnd) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e Equity) isTradingDay(day time.Time) bool {
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return false
+	}
+	return !e.Holidays[day.Format("2006-01-02")] && !IsUSHoliday(day)
+}
+
+/*
+Wall clock time d after midnight of day
+*/
+func (e Equity) timeOfDay(day time.Time, d time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, int(d/time.Minute), 0, 0, e.Location)
+}
+
+/*
+sessionStart and sessionEnd are "15:04", holidays are "02Jan2006" (same format as start_date)
+*/
+func New(name string, loc *time.Location, sessionStart string, sessionEnd string, holidays []string) (Calendar, error) {
+	switch name {
+	case "crypto":
+		return Crypto{}, nil
+	case "equity":
+		start, err := parseTimeOfDay(sessionStart)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseTimeOfDay(sessionEnd)
+		if err != nil {
+			return nil, err
+		}
+		if end <= start {
+			return nil, fmt.Errorf("Session end [%s] must be after session start [%s]", sessionEnd, sessionStart)
+		}
+		e := Equity{loc, start, end, map[string]bool{}}
+		for _, h := range holidays {
+			date, err := time.ParseInLocation("02Jan2006", h, loc)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid holiday [%s]", h)
+			}
+			e.Holidays[date.Format("2006-01-02")] = true
+		}
+		return e, nil
+	}
+	return nil, fmt.Errorf("Invalid calendar [%s] (crypto or equity)", name)
+}
+
+func parseTimeOfDay(str string) (time.Duration, error) {
+	t, err := time.Parse("15:04", str)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid time of day [%s] (15:04)", str)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+/*
+Number of open bars of intervalSeconds in a year, used to annualize returns and volatility:
+every bar for crypto, the bars overlapping a session times TradingDaysPerYear for equity
+*/
+func BarsPerYear(cal Calendar, intervalSeconds int64) float64 {
+	e, ok := cal.(Equity)
+	if !ok {
+		return float64(secondsPerYear) / float64(intervalSeconds)
+	}
+	session := (e.SessionEnd - e.SessionStart).Seconds()
+	return math.Ceil(session/float64(intervalSeconds)) * TradingDaysPerYear
+}
+
+/*
+Number of open bars of intervalSeconds starting in [start, end)
+*/
+func CountBars(cal Calendar, start int64, end int64, intervalSeconds int64) int {
+	if _, ok := cal.(Crypto); ok {
+		return int((end - start) / intervalSeconds)
+	}
+	n := 0
+	for t := start; t < end; t += intervalSeconds {
+		if cal.IsOpen(t, intervalSeconds) {
+			n += 1
+		}
+	}
+	return n
+}
+
+func FormatTime(t int64, loc *time.Location) string {
+	return time.Unix(t, 0).In(loc).Format(TimeFormat)
+}
+
+/*
+NYSE full day holidays, weekend holidays are observed on the nearest weekday (except New Year's Day on a Saturday)
+*/
+func IsUSHoliday(t time.Time) bool {
+	year, month, day := t.Date()
+	date := func(m time.Month, d int) time.Time {
+		return time.Date(year, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	holidays := []time.Time{
+		observed(date(time.January, 1)),
+		nthWeekday(year, time.January, time.Monday, 3),    // Martin Luther King Jr. Day
+		nthWeekday(year, time.February, time.Monday, 3),   // Washington's Birthday
+		easter(year).AddDate(0, 0, -2),                    // Good Friday
+		lastWeekday(year, time.May, time.Monday),          // Memorial Day
+		observed(date(time.July, 4)),                      // Independence Day
+		nthWeekday(year, time.September, time.Monday, 1),  // Labor Day
+		nthWeekday(year, time.November, time.Thursday, 4), // Thanksgiving
+		observed(date(time.December, 25)),                 // Christmas
+	}
+	if year >= 2022 {
+		holidays = append(holidays, observed(date(time.June, 19))) // Juneteenth
+	}
+	for _, h := range holidays {
+		if h.Year() == year && h.Month() == month && h.Day() == day {
+			return true
+		}
+	}
+	return false
+}
+
+func observed(t time.Time) time.Time {
+	switch t.Weekday() {
+	case time.Saturday:
+		if t.Month() == time.January && t.Day() == 1 {
+			return t
+		}
+		return t.AddDate(0, 0, -1)
+	case time.Sunday:
+		return t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+func nthWeekday(year int, month time.Month, weekday time.Weekday, n int) time.Time {
+	t := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	for t.Weekday() != weekday {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t.AddDate(0, 0, 7*(n-1))
+}
+
+func lastWeekday(year int, month time.Month, weekday time.Weekday) time.Time {
+	t := time.Date(year, month+1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+	for t.Weekday() != weekday {
+		t = t.AddDate(0, 0, -1)
+	}
+	return t
+}
+
+/*
+Gregorian Easter Sunday (anonymous Gregorian algorithm)
+*/
+func easter(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
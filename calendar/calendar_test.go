@@ -0,0 +1,116 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestEquity(t *testing.T) Calendar {
+	t.Helper()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	cal, err := New("equity", loc, "09:30", "16:00", []string{"26Dec2022"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cal
+}
+
+func TestEquityIsOpen(t *testing.T) {
+	cal := newTestEquity(t)
+	loc := cal.(Equity).Location
+	at := func(year int, month time.Month, day int, hour int, min int) int64 {
+		return time.Date(year, month, day, hour, min, 0, 0, loc).Unix()
+	}
+	for _, test := range []struct {
+		name     string
+		t        int64
+		interval int64
+		open     bool
+	}{
+		{"Daily bar at midnight", at(2023, time.March, 1, 0, 0), 86400, true},
+		{"Daily bar on a Saturday", at(2023, time.March, 4, 0, 0), 86400, false},
+		{"Daily bar on Good Friday", at(2023, time.April, 7, 0, 0), 86400, false},
+		{"Daily bar on a configured holiday", at(2022, time.December, 26, 0, 0), 86400, false},
+		{"Daily bar on the day DST starts", at(2023, time.March, 13, 0, 0), 86400, true},
+		{"Hourly bar before the open", at(2023, time.March, 1, 9, 0), 3600, true},
+		{"Hourly bar ending at the open", at(2023, time.March, 1, 8, 30), 3600, false},
+		{"Hourly bar at the close", at(2023, time.March, 1, 16, 0), 3600, false},
+		{"Last hourly bar", at(2023, time.March, 1, 15, 0), 3600, true},
+		{"Minute bar at the open", at(2023, time.March, 1, 9, 30), 60, true},
+		{"Minute bar before the open", at(2023, time.March, 1, 9, 29), 60, false},
+		{"Weekly bar from a Saturday", at(2023, time.March, 4, 0, 0), 7 * 86400, true},
+	} {
+		if open := cal.IsOpen(test.t, test.interval); open != test.open {
+			t.Errorf("%s: expected open [%v] Received [%v]", test.name, test.open, open)
+		}
+	}
+}
+
+func TestCountBars(t *testing.T) {
+	cal := newTestEquity(t)
+	loc := cal.(Equity).Location
+	start := time.Date(2023, time.February, 6, 0, 0, 0, 0, loc).Unix()
+	end := time.Date(2023, time.February, 13, 0, 0, 0, 0, loc).Unix()
+	for _, test := range []struct {
+		cal      Calendar
+		interval int64
+		bars     int
+	}{
+		{cal, 86400, 5},
+		{cal, 3600, 5 * 7},
+		{cal, 60, 5 * 390},
+		{Crypto{}, 3600, 7 * 24},
+	} {
+		if bars := CountBars(test.cal, start, end, test.interval); bars != test.bars {
+			t.Errorf("%T %ds: expected [%d] bars Received [%d]", test.cal, test.interval, test.bars, bars)
+		}
+	}
+}
+
+func TestIsUSHoliday(t *testing.T) {
+	for _, test := range []struct {
+		date    time.Time
+		holiday bool
+	}{
+		{time.Date(2022, time.January, 17, 0, 0, 0, 0, time.UTC), true},   // Martin Luther King Jr. Day
+		{time.Date(2022, time.June, 20, 0, 0, 0, 0, time.UTC), true},      // Juneteenth observed on Monday
+		{time.Date(2021, time.June, 18, 0, 0, 0, 0, time.UTC), false},     // Before Juneteenth was a holiday
+		{time.Date(2021, time.December, 31, 0, 0, 0, 0, time.UTC), false}, // New Year's Day on a Saturday is not observed
+		{time.Date(2023, time.November, 23, 0, 0, 0, 0, time.UTC), true},  // Thanksgiving
+		{time.Date(2024, time.March, 29, 0, 0, 0, 0, time.UTC), true},     // Good Friday
+	} {
+		if holiday := IsUSHoliday(test.date); holiday != test.holiday {
+			t.Errorf("%s: expected holiday [%v] Received [%v]", test.date.Format("2006-01-02"), test.holiday, holiday)
+		}
+	}
+}
+
+func TestBarsPerYear(t *testing.T) {
+	cal := newTestEquity(t)
+	for _, test := range []struct {
+		name     string
+		cal      Calendar
+		interval int64
+		expected float64
+	}{
+		{"crypto 1h", Crypto{}, 3600, 8760},
+		{"crypto 1d", Crypto{}, 86400, 365},
+		{"equity 1h", cal, 3600, 7 * 252},
+		{"equity 5m", cal, 300, 78 * 252},
+		{"equity 1d", cal, 86400, 252},
+	} {
+		if n := BarsPerYear(test.cal, test.interval); n != test.expected {
+			t.Errorf("%s: expected [%g] Received [%g]", test.name, test.expected, n)
+		}
+	}
+	// Close to the open bars of an actual year
+	loc := cal.(Equity).Location
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, loc).Unix()
+	end := time.Date(2024, 1, 1, 0, 0, 0, 0, loc).Unix()
+	if n, expected := float64(CountBars(cal, start, end, 3600)), BarsPerYear(cal, 3600); n < 0.98*expected || n > 1.02*expected {
+		t.Errorf("Expected about [%g] open bars in 2023 Received [%g]", expected, n)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+/*
+Checkpoint of a sweep:
+
+	<output_dir>/<run>.jobs: key of every job in the sweep, written before any job runs
+	<output_dir>/<run>.done: key of every job whose result was saved, appended as jobs finish
+
+A job key is asset,strat,ema,reinvest,minReturn,percentDrop,balanceTrip. Resuming a run skips the jobs in
+<run>.done and keeps writing to the same run, failed jobs are run again.
+*/
+
+type checkpoint struct {
+	doneFile string
+	done     map[string]bool
+}
+
+var runCheckpoint *checkpoint
+
+func jobKey(asset string, p ParamSet) string {
+	return asset + "," + p.String()
+}
+
+/*
+Keys of every job of the sweep, in the order they are launched
+*/
+func getJobKeys() []string {
+	keys := []string{}
+	for _, asset := range assets {
+		for _, p := range getParamGrid() {
+			keys = append(keys, jobKey(asset, p))
+		}
+	}
+	return keys
+}
+
+func newCheckpoint(runName string, keys []string) (*checkpoint, error) {
+	if _, err := os.Stat(outputDir); os.IsNotExist(err) {
+		err := os.MkdirAll(outputDir, 0755)
+		if err != nil {
+			return nil, err
+		}
+	}
+	jobsFile := fmt.Sprintf("%s/%s.jobs", outputDir, runName)
+	if err := os.WriteFile(jobsFile, []byte(strings.Join(keys, "\n")+"\n"), 0644); err != nil {
+		return nil, err
+	}
+	return &checkpoint{fmt.Sprintf("%s/%s.done", outputDir, runName), map[string]bool{}}, nil
+}
+
+/*
+Loads the checkpoint of runName, keys must match the jobs the run was started with
+*/
+func resumeCheckpoint(runName string, keys []string) (*checkpoint, error) {
+	jobs, err := readLines(fmt.Sprintf("%s/%s.jobs", outputDir, runName))
+	if err != nil {
+		return nil, fmt.Errorf("Cannot resume run [%s]: %w", runName, err)
+	}
+	if strings.Join(jobs, "\n") != strings.Join(keys, "\n") {
+		return nil, fmt.Errorf("Cannot resume run [%s]: assets or [Parameters] changed since the run was started", runName)
+	}
+	c := &checkpoint{fmt.Sprintf("%s/%s.done", outputDir, runName), map[string]bool{}}
+	done, err := readLines(c.doneFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	valid := map[string]bool{}
+	for _, key := range keys {
+		valid[key] = true
+	}
+	// A line cut short by a crash matches no job
+	for _, key := range done {
+		if valid[key] {
+			c.done[key] = true
+		}
+	}
+	return c, nil
+}
+
+func (c *checkpoint) isDone(key string) bool {
+	return c.done[key]
+}
+
+/*
+Must be called with outFileMut held
+*/
+func (c *checkpoint) markDone(key string) error {
+	f, err := os.OpenFile(c.doneFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(key + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	c.done[key] = true
+	return f.Close()
+}
+
+func readLines(fileName string) ([]string, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	lines := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
@@ -0,0 +1,114 @@
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+
+	"Simulations_v5/simulation"
+)
+
+/*
+Coordinator/worker protocol of a distributed sweep, JSON over HTTP:
+
+	GET  /api/v1/run:       RunInfo, settings every worker must share with the coordinator
+	POST /api/v1/lease:     LeaseRequest -> 200 Lease, 204 no job available yet (retry), 410 every job is finished
+	POST /api/v1/heartbeat: Heartbeat -> 200 lease extended, 409 lease lost (the job was handed to another worker)
+	POST /api/v1/result:    JobResult -> 200, 409 not a lease of this coordinator (e.g. one from before a -resume)
+
+A leased job that sees no heartbeat for the lease timeout is handed to the next worker that asks, after
+maxAttempts expired leases it is reported as failed. The first result of a job wins, later ones are ignored.
+Heartbeats and results carry the token of their lease, job IDs alone are reassigned when a run is resumed.
+*/
+
+const (
+	RunPath       = "/api/v1/run"
+	LeasePath     = "/api/v1/lease"
+	HeartbeatPath = "/api/v1/heartbeat"
+	ResultPath    = "/api/v1/result"
+)
+
+/*
+Single simulation, asset x strategy x parameter tuple
+*/
+type Job struct {
+	ID           int     `json:"id"`
+	Asset        string  `json:"asset"`
+	Strategy     string  `json:"strategy"`
+	EMA          int     `json:"ema"`
+	ReinvestPerc float64 `json:"reinvest_percentage"`
+	MinReturn    float64 `json:"min_return"`
+	PercentDrop  float64 `json:"percent_drop"`
+	BalanceTrip  float64 `json:"balance_tripwire"`
+	Attempt      int     `json:"attempt"` // 1 for the first lease of the job
+}
+
+type RunInfo struct {
+	Run      string          `json:"run"`
+	Settings json.RawMessage `json:"settings"`
+}
+
+type LeaseRequest struct {
+	Worker string `json:"worker"`
+}
+
+type Lease struct {
+	Job          Job    `json:"job"`
+	Token        string `json:"token"`         // Unique to this lease
+	LeaseSeconds int    `json:"lease_seconds"` // Heartbeats must be sent more often than this
+}
+
+type Heartbeat struct {
+	Worker string `json:"worker"`
+	JobID  int    `json:"job_id"`
+	Token  string `json:"token"`
+}
+
+type JobResult struct {
+	JobID        int                `json:"job_id"`
+	Token        string             `json:"token"` // Token of the lease the job was run under
+	Worker       string             `json:"worker"`
+	AssetName    string             `json:"asset"`
+	ResultString string             `json:"result_string"`
+	FinalValue   float64            `json:"final_value"`
+	Equity       []float64          `json:"equity"`
+	Trades       []simulation.Trade `json:"trades"`
+	OpenTrades   []simulation.Trade `json:"open_trades"`
+	Err          string             `json:"error,omitempty"`
+}
+
+func NewJobResult(lease Lease, worker string, r simulation.Result) JobResult {
+	job := lease.Job
+	res := JobResult{
+		JobID:        job.ID,
+		Token:        lease.Token,
+		Worker:       worker,
+		AssetName:    r.AssetName,
+		ResultString: r.ResultString,
+		FinalValue:   r.FinalValue,
+		Equity:       r.Equity,
+		Trades:       r.Trades,
+		OpenTrades:   r.OpenTrades,
+	}
+	if res.AssetName == "" {
+		res.AssetName = job.Asset
+	}
+	if r.Err != nil {
+		res.Err = r.Err.Error()
+	}
+	return res
+}
+
+func (r JobResult) Result() simulation.Result {
+	res := simulation.Result{
+		AssetName:    r.AssetName,
+		ResultString: r.ResultString,
+		FinalValue:   r.FinalValue,
+		Equity:       r.Equity,
+		Trades:       r.Trades,
+		OpenTrades:   r.OpenTrades,
+	}
+	if r.Err != "" {
+		res.Err = errors.New(r.Err)
+	}
+	return res
+}
@@ -0,0 +1,105 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"Simulations_v5/simulation"
+)
+
+func newTestJobs(n int) []Job {
+	jobs := []Job{}
+	for id := 0; id < n; id++ {
+		jobs = append(jobs, Job{ID: id, Asset: fmt.Sprintf("ASSET%d", id), Strategy: "MACD", EMA: 50})
+	}
+	return jobs
+}
+
+func postTestJSON(t *testing.T, url string, in interface{}, out interface{}) int {
+	t.Helper()
+	body, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK && out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestWorkerRunsEveryJob(t *testing.T) {
+	jobs := newTestJobs(5)
+	coordinator := NewCoordinator(RunInfo{Run: "TEST"}, jobs, time.Minute, 3)
+	server := httptest.NewServer(coordinator.Handler())
+	defer server.Close()
+
+	worker := NewWorker(server.URL, "worker", 0)
+	info, err := worker.RunInfo(context.Background())
+	if err != nil || info.Run != "TEST" {
+		t.Fatalf("Expected run [TEST] Received [%s] %v", info.Run, err)
+	}
+	err = worker.Run(context.Background(), 2, func(ctx context.Context, job Job) simulation.Result {
+		return simulation.Result{AssetName: job.Asset, FinalValue: float64(job.ID), Equity: []float64{100, float64(job.ID)}}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := map[int]JobResult{}
+	for res := range coordinator.Results() {
+		received[res.JobID] = res
+	}
+	if len(received) != len(jobs) {
+		t.Fatalf("Expected [%d] results Received [%d]", len(jobs), len(received))
+	}
+	for _, job := range jobs {
+		r := received[job.ID].Result()
+		if r.AssetName != job.Asset || r.FinalValue != float64(job.ID) || len(r.Equity) != 2 || r.Err != nil {
+			t.Errorf("Job %d: unexpected result %+v", job.ID, r)
+		}
+	}
+}
+
+func TestCoordinatorRejectsForeignLeases(t *testing.T) {
+	coordinator := NewCoordinator(RunInfo{Run: "TEST"}, newTestJobs(1), time.Minute, 3)
+	server := httptest.NewServer(coordinator.Handler())
+	defer server.Close()
+
+	lease := Lease{}
+	if status := postTestJSON(t, server.URL+LeasePath, LeaseRequest{"worker"}, &lease); status != http.StatusOK || lease.Token == "" {
+		t.Fatalf("Expected a lease with a token Received [%d] %+v", status, lease)
+	}
+	// A worker of a previous coordinator of the run, its job 0 may have been another job
+	stale := Lease{Job: lease.Job, Token: "stale"}
+	if status := postTestJSON(t, server.URL+HeartbeatPath, Heartbeat{"worker", 0, stale.Token}, nil); status != http.StatusConflict {
+		t.Errorf("Heartbeat of a foreign lease: expected [409] Received [%d]", status)
+	}
+	if status := postTestJSON(t, server.URL+ResultPath, NewJobResult(stale, "worker", simulation.Result{}), nil); status != http.StatusConflict {
+		t.Errorf("Result of a foreign lease: expected [409] Received [%d]", status)
+	}
+	if status := postTestJSON(t, server.URL+HeartbeatPath, Heartbeat{"worker", 0, lease.Token}, nil); status != http.StatusOK {
+		t.Errorf("Heartbeat of the lease: expected [200] Received [%d]", status)
+	}
+	if status := postTestJSON(t, server.URL+ResultPath, NewJobResult(lease, "worker", simulation.Result{FinalValue: 1}), nil); status != http.StatusOK {
+		t.Errorf("Result of the lease: expected [200] Received [%d]", status)
+	}
+	results := []JobResult{}
+	for res := range coordinator.Results() {
+		results = append(results, res)
+	}
+	if len(results) != 1 || results[0].FinalValue != 1 {
+		t.Errorf("Expected the one result of the lease Received %+v", results)
+	}
+}
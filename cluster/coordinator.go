@@ -0,0 +1,223 @@
+package cluster
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/*
+Hands out jobs to workers (see cluster.go), every finished job is sent on Results exactly once
+and Results is closed once every job is finished.
+*/
+type Coordinator struct {
+	info         RunInfo
+	leaseTimeout time.Duration
+	maxAttempts  int
+	mut          sync.Mutex
+	jobs         map[int]*jobState
+	pending      []int // IDs of jobs waiting for a worker, in order
+	remaining    int
+	results      chan JobResult
+}
+
+type jobState struct {
+	job      Job
+	worker   string
+	token    string          // Token of the current lease
+	tokens   map[string]bool // Every lease of the job, a worker whose lease expired may still deliver
+	expires  time.Time
+	finished bool
+}
+
+func NewCoordinator(info RunInfo, jobs []Job, leaseTimeout time.Duration, maxAttempts int) *Coordinator {
+	c := &Coordinator{
+		info:         info,
+		leaseTimeout: leaseTimeout,
+		maxAttempts:  maxAttempts,
+		jobs:         map[int]*jobState{},
+		remaining:    len(jobs),
+		results:      make(chan JobResult, len(jobs)),
+	}
+	for _, job := range jobs {
+		c.jobs[job.ID] = &jobState{job: job, tokens: map[string]bool{}}
+		c.pending = append(c.pending, job.ID)
+	}
+	if c.remaining == 0 {
+		close(c.results)
+	}
+	return c
+}
+
+func (c *Coordinator) Results() <-chan JobResult {
+	return c.results
+}
+
+func (c *Coordinator) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(RunPath, c.handleRun)
+	mux.HandleFunc(LeasePath, c.handleLease)
+	mux.HandleFunc(HeartbeatPath, c.handleHeartbeat)
+	mux.HandleFunc(ResultPath, c.handleResult)
+	return mux
+}
+
+/*
+Number of jobs leased to a worker
+*/
+func (c *Coordinator) Leased() int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	n := 0
+	for _, s := range c.jobs {
+		if s.worker != "" {
+			n += 1
+		}
+	}
+	return n
+}
+
+/*
+Requeues jobs whose lease expired, a job out of attempts is finished with an error
+*/
+func (c *Coordinator) Reap() {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.reap()
+}
+
+func (c *Coordinator) reap() {
+	now := time.Now()
+	for id, s := range c.jobs {
+		if s.finished || s.worker == "" || now.Before(s.expires) {
+			continue
+		}
+		worker := s.worker
+		s.worker = ""
+		s.token = ""
+		if s.job.Attempt >= c.maxAttempts {
+			c.finish(s, JobResult{JobID: id, Worker: worker, AssetName: s.job.Asset,
+				Err: fmt.Sprintf("[%s] Lease of job %d expired %d times, last worker [%s]", s.job.Asset, id, s.job.Attempt, worker)})
+			continue
+		}
+		c.pending = append(c.pending, id)
+	}
+}
+
+/*
+Must be called with mut held
+*/
+func (c *Coordinator) finish(s *jobState, r JobResult) {
+	s.finished = true
+	s.worker = ""
+	s.token = ""
+	c.remaining -= 1
+	c.results <- r
+	if c.remaining == 0 {
+		close(c.results)
+	}
+}
+
+func (c *Coordinator) handleRun(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, c.info)
+}
+
+func (c *Coordinator) handleLease(w http.ResponseWriter, r *http.Request) {
+	req := LeaseRequest{}
+	if !readJSON(w, r, &req) {
+		return
+	}
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.reap()
+	if c.remaining == 0 {
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+	for len(c.pending) > 0 {
+		id := c.pending[0]
+		c.pending = c.pending[1:]
+		s := c.jobs[id]
+		// A job requeued after an expired lease may have been finished by its previous worker since
+		if s.finished {
+			continue
+		}
+		token, err := newToken()
+		if err != nil {
+			c.pending = append([]int{id}, c.pending...)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.worker = req.Worker
+		s.token = token
+		s.tokens[token] = true
+		s.expires = time.Now().Add(c.leaseTimeout)
+		s.job.Attempt += 1
+		writeJSON(w, Lease{s.job, token, int(c.leaseTimeout.Seconds())})
+		return
+	}
+	w.Header().Set("Retry-After", "1")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *Coordinator) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	req := Heartbeat{}
+	if !readJSON(w, r, &req) {
+		return
+	}
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	s, ok := c.jobs[req.JobID]
+	if !ok || s.finished || s.token == "" || s.token != req.Token {
+		http.Error(w, "lease lost", http.StatusConflict)
+		return
+	}
+	s.expires = time.Now().Add(c.leaseTimeout)
+}
+
+func (c *Coordinator) handleResult(w http.ResponseWriter, r *http.Request) {
+	res := JobResult{}
+	if !readJSON(w, r, &res) {
+		return
+	}
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	s, ok := c.jobs[res.JobID]
+	if !ok || !s.tokens[res.Token] {
+		http.Error(w, fmt.Sprintf("job %d was not leased with token [%s]", res.JobID, res.Token), http.StatusConflict)
+		return
+	}
+	// A worker whose lease expired may still deliver, the job is done either way
+	if !s.finished {
+		c.finish(s, res)
+	}
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,190 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"Simulations_v5/simulation"
+)
+
+/*
+Client of a coordinator (see cluster.go). Requests that fail to reach the coordinator are retried with an
+exponential backoff starting at one second, up to maxRetries times in a row.
+*/
+type Worker struct {
+	baseURL    string
+	name       string
+	http       *http.Client
+	maxRetries int
+}
+
+var errLeaseLost = errors.New("lease lost")
+
+func NewWorker(baseURL string, name string, maxRetries int) *Worker {
+	return &Worker{
+		baseURL:    baseURL,
+		name:       name,
+		http:       &http.Client{Timeout: 30 * time.Second},
+		maxRetries: maxRetries,
+	}
+}
+
+func (w *Worker) Name() string {
+	return w.name
+}
+
+func (w *Worker) RunInfo(ctx context.Context) (RunInfo, error) {
+	info := RunInfo{}
+	_, err := w.do(ctx, http.MethodGet, RunPath, nil, &info)
+	return info, err
+}
+
+/*
+Leases jobs and runs them with run on parallel goroutines until every job of the run is finished or ctx is cancelled.
+Jobs running when ctx is cancelled are not reported, their leases expire and they are handed to another worker.
+*/
+func (w *Worker) Run(ctx context.Context, parallel int, run func(ctx context.Context, job Job) simulation.Result) error {
+	errs := make([]error, parallel)
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = w.loop(ctx, run)
+		}(i)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return errors.Join(errs...)
+}
+
+func (w *Worker) loop(ctx context.Context, run func(ctx context.Context, job Job) simulation.Result) error {
+	for ctx.Err() == nil {
+		lease := Lease{}
+		status, err := w.do(ctx, http.MethodPost, LeasePath, LeaseRequest{w.name}, &lease)
+		if err != nil {
+			return err
+		}
+		switch status {
+		case http.StatusGone:
+			return nil
+		case http.StatusNoContent:
+			sleep(ctx, time.Second)
+			continue
+		}
+		r, err := w.runLeased(ctx, lease, run)
+		if errors.Is(err, errLeaseLost) || ctx.Err() != nil {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := w.do(ctx, http.MethodPost, ResultPath, NewJobResult(lease, w.name, r), nil); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+/*
+Runs the job of lease, sending heartbeats until it finishes. A lost lease cancels the job.
+*/
+func (w *Worker) runLeased(ctx context.Context, lease Lease, run func(ctx context.Context, job Job) simulation.Result) (simulation.Result, error) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	interval := time.Duration(lease.LeaseSeconds) * time.Second / 3
+	if interval <= 0 {
+		interval = time.Second
+	}
+	lost := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-jobCtx.Done():
+				return
+			case <-ticker.C:
+				status, err := w.do(jobCtx, http.MethodPost, HeartbeatPath, Heartbeat{w.name, lease.Job.ID, lease.Token}, nil)
+				if err == nil && status == http.StatusConflict {
+					close(lost)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	r := run(jobCtx, lease.Job)
+	close(done)
+	select {
+	case <-lost:
+		return r, fmt.Errorf("[%s] Job %d: %w", lease.Job.Asset, lease.Job.ID, errLeaseLost)
+	default:
+	}
+	return r, nil
+}
+
+/*
+Sends a request to the coordinator and decodes a 200 response into out (if not nil), returns the status code.
+Statuses other than 200, 204, 409 and 410 are errors.
+*/
+func (w *Worker) do(ctx context.Context, method string, path string, in interface{}, out interface{}) (int, error) {
+	var body []byte
+	if in != nil {
+		var err error
+		body, err = json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+	}
+	backoff := time.Second
+	for retry := 0; ; retry++ {
+		req, err := http.NewRequestWithContext(ctx, method, w.baseURL+path, bytes.NewReader(body))
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := w.http.Do(req)
+		if err != nil {
+			if ctx.Err() != nil || retry >= w.maxRetries {
+				return 0, err
+			}
+			sleep(ctx, backoff)
+			backoff *= 2
+			continue
+		}
+		defer resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusOK:
+			if out != nil {
+				if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+					return resp.StatusCode, err
+				}
+			}
+			return resp.StatusCode, nil
+		case http.StatusNoContent, http.StatusConflict, http.StatusGone:
+			return resp.StatusCode, nil
+		}
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("%s%s: %s %s", w.baseURL, path, resp.Status, bytes.TrimSpace(msg))
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
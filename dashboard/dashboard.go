@@ -0,0 +1,25 @@
+package dashboard
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+/*
+Web dashboard served by serve: lists runs, filters and sorts their results and shows the report of a single
+simulation (price, indicators, trades and reserve events). Plain HTML, CSS and JavaScript reading the JSON API
+of serve, nothing is loaded from the network.
+*/
+
+//go:embed static
+var static embed.FS
+
+func Handler() http.Handler {
+	// static is part of the binary, Sub only fails for an invalid directory name
+	files, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServer(http.FS(files))
+}
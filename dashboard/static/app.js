@@ -0,0 +1,177 @@
+'use strict';
+
+// Views: #  (runs) and #run=<name> (results of a run, a row opens its report)
+
+const paramColumns = [
+	['asset', 'Asset'],
+	['strategy', 'Strategy'],
+	['ema', 'EMA'],
+	['reinvest_percentage', 'Reinvest'],
+	['min_return', 'Min Return'],
+	['percent_drop', 'Percent Drop'],
+	['balance_tripwire', 'Balance Tripwire'],
+];
+
+let current = null; // {run, metrics, results}
+
+function $(id) {
+	return document.getElementById(id);
+}
+
+async function getJSON(url) {
+	const resp = await fetch(url);
+	const body = await resp.json();
+	if (!resp.ok) {
+		throw new Error(body.error || resp.statusText);
+	}
+	return body;
+}
+
+function cell(tr, text) {
+	const td = document.createElement('td');
+	td.textContent = text;
+	tr.appendChild(td);
+}
+
+function formatTime(t) {
+	return t ? new Date(t).toLocaleString() : '';
+}
+
+// Result strings hold percent_drop negated, show it as configured
+function param(r, key) {
+	return key === 'percent_drop' ? -r[key] : r[key];
+}
+
+function formatNumber(v) {
+	return Number.isInteger(v) ? String(v) : v.toFixed(2);
+}
+
+function showError(err) {
+	$('error').textContent = err ? String(err.message || err) : '';
+}
+
+async function showRuns() {
+	$('runs-view').hidden = false;
+	$('results-view').hidden = true;
+	$('title').textContent = '';
+	const runs = await getJSON('/api/v1/runs');
+	const tbody = $('runs').querySelector('tbody');
+	tbody.replaceChildren();
+	for (const run of runs) {
+		const tr = document.createElement('tr');
+		cell(tr, run.run);
+		cell(tr, formatTime(run.started));
+		cell(tr, run.finished ? formatTime(run.finished) : 'not finished');
+		cell(tr, run.assets.join(' '));
+		cell(tr, run.strategies.join(' '));
+		cell(tr, run.jobs);
+		cell(tr, run.completed);
+		cell(tr, run.failed);
+		cell(tr, (run.git_commit || '').slice(0, 10) + (run.git_dirty ? ' (modified)' : ''));
+		tr.onclick = () => { location.hash = 'run=' + encodeURIComponent(run.run); };
+		tbody.appendChild(tr);
+	}
+}
+
+function setOptions(select, values, keepFirst) {
+	const first = keepFirst ? select.options[0] : null;
+	select.replaceChildren();
+	if (first) {
+		select.appendChild(first);
+	}
+	for (const v of values) {
+		const option = document.createElement('option');
+		option.value = option.textContent = v;
+		select.appendChild(option);
+	}
+}
+
+function distinct(results, key) {
+	return [...new Set(results.map(r => r[key]))].sort((a, b) => (a < b ? -1 : a > b ? 1 : 0));
+}
+
+async function showResults(run) {
+	$('runs-view').hidden = true;
+	$('results-view').hidden = false;
+	$('report-view').hidden = true;
+	$('title').textContent = '/ ' + run;
+	const body = await getJSON('/api/v1/runs/' + encodeURIComponent(run) + '/results');
+	current = {run: run, metrics: body.metrics, results: body.results};
+	setOptions($('asset'), distinct(body.results, 'asset'), true);
+	setOptions($('strategy'), distinct(body.results, 'strategy'), true);
+	setOptions($('ema'), distinct(body.results, 'ema'), true);
+	setOptions($('metric'), body.metrics, false);
+	$('metric').value = body.metrics.includes('profit') ? 'profit' : body.metrics[0];
+	const thead = $('results').querySelector('thead');
+	const tr = document.createElement('tr');
+	for (const [, name] of paramColumns) {
+		const th = document.createElement('th');
+		th.textContent = name;
+		tr.appendChild(th);
+	}
+	for (const metric of body.metrics) {
+		const th = document.createElement('th');
+		th.textContent = metric;
+		th.onclick = () => { $('metric').value = metric; renderResults(); };
+		tr.appendChild(th);
+	}
+	thead.replaceChildren(tr);
+	renderResults();
+}
+
+function renderResults() {
+	const asset = $('asset').value;
+	const strategy = $('strategy').value;
+	const ema = $('ema').value;
+	const metric = $('metric').value;
+	const sign = $('ascending').checked ? 1 : -1;
+	const limit = Number($('limit').value);
+	const rows = current.results.filter(r =>
+		(!asset || r.asset === asset) && (!strategy || r.strategy === strategy) && (!ema || String(r.ema) === ema));
+	rows.sort((a, b) => sign * (a.metrics[metric] - b.metrics[metric]));
+	$('count').textContent = `${rows.length} of ${current.results.length} results` + (rows.length > limit ? `, showing ${limit}` : '');
+	const tbody = $('results').querySelector('tbody');
+	tbody.replaceChildren();
+	for (const r of rows.slice(0, limit)) {
+		const tr = document.createElement('tr');
+		for (const [key] of paramColumns) {
+			cell(tr, param(r, key));
+		}
+		for (const m of current.metrics) {
+			cell(tr, formatNumber(r.metrics[m]));
+		}
+		tr.onclick = () => showReport(r, tr);
+		tbody.appendChild(tr);
+	}
+}
+
+function showReport(r, tr) {
+	for (const selected of document.querySelectorAll('tr.selected')) {
+		selected.classList.remove('selected');
+	}
+	tr.classList.add('selected');
+	$('report-view').hidden = false;
+	$('report-title').textContent = `${r.asset} ${r.strategy} EMA-${r.ema} MPBR-${r.min_return}_${param(r, 'percent_drop')}_${r.balance_tripwire}_${r.reinvest_percentage} (row ${r.row})`;
+	$('report').src = '/api/v1/runs/' + encodeURIComponent(current.run) + '/results/' + encodeURIComponent(r.asset) + '/' + r.row + '/report';
+	$('report-view').scrollIntoView();
+}
+
+async function route() {
+	showError(null);
+	const params = new URLSearchParams(location.hash.slice(1));
+	try {
+		if (params.get('run')) {
+			await showResults(params.get('run'));
+		} else {
+			await showRuns();
+		}
+	} catch (err) {
+		showError(err);
+	}
+}
+
+for (const id of ['asset', 'strategy', 'ema', 'metric', 'ascending', 'limit']) {
+	$(id).onchange = renderResults;
+}
+window.onhashchange = route;
+route();
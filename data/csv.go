@@ -0,0 +1,97 @@
+package data
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type CSVProvider struct{}
+
+type GzipCSVProvider struct{}
+
+func (CSVProvider) Load(asset string, fileName string, window Window) (BarSet, error) {
+	b, hash, err := readFile(fileName)
+	if err != nil {
+		return BarSet{}, err
+	}
+	set, err := readCSV(asset, bytes.NewReader(b), window)
+	set.Hash = hash
+	return set, err
+}
+
+func (GzipCSVProvider) Load(asset string, fileName string, window Window) (BarSet, error) {
+	b, hash, err := readFile(fileName)
+	if err != nil {
+		return BarSet{}, err
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return BarSet{}, fmt.Errorf("[%s] %s: %v", asset, fileName, err)
+	}
+	defer gz.Close()
+	set, err := readCSV(asset, gz, window)
+	set.Hash = hash
+	return set, err
+}
+
+/*
+Empty and non-numeric values are read as NaN
+*/
+func readCSV(asset string, r io.Reader, window Window) (BarSet, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return BarSet{}, err
+	}
+	dateCol := -1
+	columns := []string{}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if name == "Date" {
+			dateCol = i
+			continue
+		}
+		columns = append(columns, name)
+	}
+	if dateCol < 0 {
+		return BarSet{}, fmt.Errorf("[%s] Data is missing columns [Date]", asset)
+	}
+	builder, err := newBarBuilder(asset, columns, window)
+	if err != nil {
+		return BarSet{}, err
+	}
+	values := make([]float64, len(columns))
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return BarSet{}, err
+		}
+		date, err := strconv.ParseInt(strings.TrimSpace(record[dateCol]), 10, 64)
+		if err != nil {
+			return BarSet{}, fmt.Errorf("[%s] Invalid Date [%s] on line %d", asset, record[dateCol], line)
+		}
+		v := 0
+		for i, field := range record {
+			if i == dateCol {
+				continue
+			}
+			values[v], err = strconv.ParseFloat(strings.TrimSpace(field), 64)
+			if err != nil {
+				values[v] = math.NaN()
+			}
+			v += 1
+		}
+		builder.add(date, values)
+	}
+	return builder.set, nil
+}
@@ -0,0 +1,129 @@
+package data
+
+import (
+	"math"
+)
+
+/*
+Indicators recomputed after resampling:
+
+	EMA_<n>: exponential moving average of Close
+	MACD:    EMA(12) - EMA(26) of Close
+	SIGNAL:  EMA(9) of MACD
+	dP:      change in Close from the previous bar
+	SAR:     parabolic SAR (requires High and Low)
+	CHAI:    Chaikin oscillator, EMA(3) - EMA(10) of the accumulation/distribution line (requires High, Low and Volume)
+*/
+
+const (
+	sarStep = 0.02
+	sarMax  = 0.2
+)
+
+/*
+Exponential moving average seeded with the first value, NaN values carry the previous average forward
+*/
+func EMA(values []float64, n int) []float64 {
+	out := make([]float64, len(values))
+	alpha := 2.0 / float64(n+1)
+	prev := math.NaN()
+	for i, v := range values {
+		switch {
+		case math.IsNaN(v):
+			out[i] = prev
+			continue
+		case math.IsNaN(prev):
+			prev = v
+		default:
+			prev = alpha*v + (1-alpha)*prev
+		}
+		out[i] = prev
+	}
+	return out
+}
+
+func MACD(closes []float64) ([]float64, []float64) {
+	fast := EMA(closes, 12)
+	slow := EMA(closes, 26)
+	macd := make([]float64, len(closes))
+	for i := range closes {
+		macd[i] = fast[i] - slow[i]
+	}
+	return macd, EMA(macd, 9)
+}
+
+func PriceChange(closes []float64) []float64 {
+	out := make([]float64, len(closes))
+	for i := 1; i < len(closes); i++ {
+		out[i] = closes[i] - closes[i-1]
+	}
+	return out
+}
+
+/*
+Wilder's parabolic SAR, the first bar starts an uptrend
+*/
+func ParabolicSAR(highs []float64, lows []float64) []float64 {
+	out := make([]float64, len(highs))
+	if len(highs) == 0 {
+		return out
+	}
+	up := true
+	af := sarStep
+	ep := highs[0]
+	sar := lows[0]
+	out[0] = sar
+	for i := 1; i < len(highs); i++ {
+		sar = sar + af*(ep-sar)
+		if up {
+			sar = math.Min(sar, lows[i-1])
+			if i > 1 {
+				sar = math.Min(sar, lows[i-2])
+			}
+			if lows[i] < sar {
+				up = false
+				sar = ep
+				ep = lows[i]
+				af = sarStep
+			} else if highs[i] > ep {
+				ep = highs[i]
+				af = math.Min(af+sarStep, sarMax)
+			}
+		} else {
+			sar = math.Max(sar, highs[i-1])
+			if i > 1 {
+				sar = math.Max(sar, highs[i-2])
+			}
+			if highs[i] > sar {
+				up = true
+				sar = ep
+				ep = highs[i]
+				af = sarStep
+			} else if lows[i] < ep {
+				ep = lows[i]
+				af = math.Min(af+sarStep, sarMax)
+			}
+		}
+		out[i] = sar
+	}
+	return out
+}
+
+func Chaikin(highs []float64, lows []float64, closes []float64, volumes []float64) []float64 {
+	adl := make([]float64, len(closes))
+	total := 0.0
+	for i := range closes {
+		if highs[i] > lows[i] {
+			mfm := ((closes[i] - lows[i]) - (highs[i] - closes[i])) / (highs[i] - lows[i])
+			total += mfm * volumes[i]
+		}
+		adl[i] = total
+	}
+	fast := EMA(adl, 3)
+	slow := EMA(adl, 10)
+	out := make([]float64, len(closes))
+	for i := range closes {
+		out[i] = fast[i] - slow[i]
+	}
+	return out
+}
@@ -0,0 +1,50 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/go-gota/gota/dataframe"
+)
+
+/*
+Supported bar intervals (see [Data] interval) in seconds
+*/
+var intervals = map[string]int64{
+	"1m": 60,
+	"5m": 5 * 60,
+	"1h": 60 * 60,
+	"4h": 4 * 60 * 60,
+	"1d": 24 * 60 * 60,
+}
+
+func ParseInterval(interval string) (int64, error) {
+	seconds, ok := intervals[interval]
+	if !ok {
+		return 0, fmt.Errorf("Invalid interval [%s] (1m, 5m, 1h, 4h or 1d)", interval)
+	}
+	return seconds, nil
+}
+
+/*
+Median number of seconds between consecutive Dates (0 if there are fewer than 2 distinct Dates)
+*/
+func DetectInterval(dataFrame dataframe.DataFrame) (int64, error) {
+	dates, err := dataFrame.Col("Date").Int()
+	if err != nil {
+		return 0, err
+	}
+	sorted := append([]int{}, dates...)
+	sort.Ints(sorted)
+	diffs := []int{}
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i] > sorted[i-1] {
+			diffs = append(diffs, sorted[i]-sorted[i-1])
+		}
+	}
+	if len(diffs) == 0 {
+		return 0, nil
+	}
+	sort.Ints(diffs)
+	return int64(diffs[len(diffs)/2]), nil
+}
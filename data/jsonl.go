@@ -0,0 +1,83 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+/*
+One JSON object per line, columns are taken from the first line (OHLCV first, then the others by name).
+Missing and null values are read as NaN.
+*/
+type JSONLProvider struct{}
+
+func (JSONLProvider) Load(asset string, fileName string, window Window) (BarSet, error) {
+	b, hash, err := readFile(fileName)
+	if err != nil {
+		return BarSet{}, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	var builder *barBuilder
+	var values []float64
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum += 1
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		row := map[string]*float64{}
+		if err := json.Unmarshal([]byte(line), &row); err != nil {
+			return BarSet{}, fmt.Errorf("%s:%d: %v", fileName, lineNum, err)
+		}
+		date, ok := row["Date"]
+		if !ok || date == nil {
+			return BarSet{}, fmt.Errorf("%s:%d: missing Date", fileName, lineNum)
+		}
+		if builder == nil {
+			builder, err = newBarBuilder(asset, getJSONLColumns(row), window)
+			if err != nil {
+				return BarSet{}, err
+			}
+			values = make([]float64, len(builder.set.Columns))
+		}
+		for i, name := range builder.set.Columns {
+			values[i] = math.NaN()
+			if v, ok := row[name]; ok && v != nil {
+				values[i] = *v
+			}
+		}
+		builder.add(int64(*date), values)
+	}
+	if err := scanner.Err(); err != nil {
+		return BarSet{}, err
+	}
+	if builder == nil {
+		return BarSet{}, fmt.Errorf("[%s] %s is empty", asset, fileName)
+	}
+	builder.set.Hash = hash
+	return builder.set, nil
+}
+
+func getJSONLColumns(row map[string]*float64) []string {
+	columns := []string{}
+	for _, name := range []string{"Open", "High", "Low", "Close", "Volume"} {
+		if _, ok := row[name]; ok {
+			columns = append(columns, name)
+		}
+	}
+	others := []string{}
+	for name := range row {
+		if name != "Date" && barField(&Bar{}, name) == nil {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	return append(columns, others...)
+}
@@ -0,0 +1,100 @@
+package data
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+/*
+Reads flat Parquet files, Date must be an integer column, every other column is read as a float
+(null values are NaN)
+*/
+type ParquetProvider struct{}
+
+const parquetBatchSize = 1024
+
+func (ParquetProvider) Load(asset string, fileName string, window Window) (BarSet, error) {
+	b, hash, err := readFile(fileName)
+	if err != nil {
+		return BarSet{}, err
+	}
+	f := bytes.NewReader(b)
+	file, err := parquet.OpenFile(f, f.Size())
+	if err != nil {
+		return BarSet{}, fmt.Errorf("[%s] %s: %v", asset, fileName, err)
+	}
+	dateCol := -1
+	columns := []string{}
+	columnIndex := map[int]int{} // Parquet column -> index in columns
+	for i, path := range file.Schema().Columns() {
+		name := strings.Join(path, ".")
+		if name == "Date" {
+			dateCol = i
+			continue
+		}
+		columnIndex[i] = len(columns)
+		columns = append(columns, name)
+	}
+	if dateCol < 0 {
+		return BarSet{}, fmt.Errorf("[%s] Data is missing columns [Date]", asset)
+	}
+	builder, err := newBarBuilder(asset, columns, window)
+	if err != nil {
+		return BarSet{}, err
+	}
+	reader := parquet.NewReader(f)
+	defer reader.Close()
+	rows := make([]parquet.Row, parquetBatchSize)
+	values := make([]float64, len(columns))
+	for {
+		n, err := reader.ReadRows(rows)
+		for _, row := range rows[:n] {
+			var date int64
+			hasDate := false
+			for i := range values {
+				values[i] = math.NaN()
+			}
+			for _, v := range row {
+				if v.IsNull() {
+					continue
+				}
+				if v.Column() == dateCol {
+					date, hasDate = int64(parquetFloat(v)), true
+				} else if i, ok := columnIndex[v.Column()]; ok {
+					values[i] = parquetFloat(v)
+				}
+			}
+			if !hasDate {
+				return BarSet{}, fmt.Errorf("[%s] %s: row without a Date", asset, fileName)
+			}
+			builder.add(date, values)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return BarSet{}, err
+		}
+	}
+	builder.set.Hash = hash
+	return builder.set, nil
+}
+
+func parquetFloat(v parquet.Value) float64 {
+	switch v.Kind() {
+	case parquet.Int32:
+		return float64(v.Int32())
+	case parquet.Int64:
+		return float64(v.Int64())
+	case parquet.Float:
+		return float64(v.Float())
+	case parquet.Double:
+		return v.Double()
+	}
+	return math.NaN()
+}
@@ -0,0 +1,176 @@
+package data
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+)
+
+/*
+Readers of data files (see [Files] data_provider):
+
+	csv:     plain CSV with a header row (default)
+	csv.gz:  gzipped CSV
+	parquet: Parquet with one numeric column per field
+	jsonl:   JSON Lines, one object per bar
+	auto:    chosen by the file extension (.csv, .csv.gz/.gz, .parquet, .jsonl)
+
+Every file has a Date column (Unix seconds) and a Close column, Open, High, Low and Volume are optional.
+Every other column is read as a float (indicators such as EMA_<n>, MACD, SIGNAL, ...).
+*/
+type DataProvider interface {
+	Load(asset string, fileName string, window Window) (BarSet, error)
+}
+
+var providers = map[string]DataProvider{
+	"csv":     CSVProvider{},
+	"csv.gz":  GzipCSVProvider{},
+	"parquet": ParquetProvider{},
+	"jsonl":   JSONLProvider{},
+	"auto":    AutoProvider{},
+}
+
+/*
+Dates (Unix seconds) of the bars to load, inclusive. Zero is unbounded.
+*/
+type Window struct {
+	Start int64
+	End   int64
+}
+
+func (w Window) contains(date int64) bool {
+	return (w.Start == 0 || date >= w.Start) && (w.End == 0 || date <= w.End)
+}
+
+type BarSet struct {
+	Asset   string
+	Columns []string             // Columns present besides Date, in file order
+	Bars    []Bar                // Missing Open, High, Low and Volume columns are NaN
+	Extra   map[string][]float64 // Columns other than Open, High, Low, Close and Volume, one value per bar
+	Hash    string               // sha256 of the file the bars were read from
+}
+
+/*
+Contents of fileName and their sha256, the file is read once so the hash describes the bars read from it
+*/
+func readFile(fileName string) ([]byte, string, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(b)
+	return b, hex.EncodeToString(sum[:]), nil
+}
+
+func GetProvider(name string) (DataProvider, error) {
+	provider, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("Invalid data provider [%s] (csv, csv.gz, parquet, jsonl or auto)", name)
+	}
+	return provider, nil
+}
+
+/*
+Converts the bars to a DataFrame with the same columns as the data file
+*/
+func (b BarSet) DataFrame() dataframe.DataFrame {
+	dates := make([]int, len(b.Bars))
+	for i, bar := range b.Bars {
+		dates[i] = int(bar.Date)
+	}
+	columns := []series.Series{series.New(dates, series.Int, "Date")}
+	for _, name := range b.Columns {
+		values, ok := b.Extra[name]
+		if !ok {
+			values = make([]float64, len(b.Bars))
+			for i, bar := range b.Bars {
+				values[i] = *barField(&bar, name)
+			}
+		}
+		columns = append(columns, series.New(values, series.Float, name))
+	}
+	return dataframe.New(columns...)
+}
+
+type AutoProvider struct{}
+
+func (AutoProvider) Load(asset string, fileName string, window Window) (BarSet, error) {
+	name := strings.ToLower(fileName)
+	switch {
+	case strings.HasSuffix(name, ".csv"):
+		return CSVProvider{}.Load(asset, fileName, window)
+	case strings.HasSuffix(name, ".gz"):
+		return GzipCSVProvider{}.Load(asset, fileName, window)
+	case strings.HasSuffix(name, ".parquet"):
+		return ParquetProvider{}.Load(asset, fileName, window)
+	case strings.HasSuffix(name, ".jsonl"):
+		return JSONLProvider{}.Load(asset, fileName, window)
+	}
+	return BarSet{}, fmt.Errorf("[%s] No data provider for [%s]", asset, filepath.Base(fileName))
+}
+
+/*
+Field of bar holding the named OHLCV column (nil for any other column)
+*/
+func barField(bar *Bar, name string) *float64 {
+	switch name {
+	case "Open":
+		return &bar.Open
+	case "High":
+		return &bar.High
+	case "Low":
+		return &bar.Low
+	case "Close":
+		return &bar.Close
+	case "Volume":
+		return &bar.Volume
+	}
+	return nil
+}
+
+/*
+Collects rows into a BarSet, values are aligned with columns (Date excluded)
+*/
+type barBuilder struct {
+	set    BarSet
+	window Window
+}
+
+func newBarBuilder(asset string, columns []string, window Window) (*barBuilder, error) {
+	set := BarSet{Asset: asset, Columns: columns, Extra: map[string][]float64{}}
+	hasClose := false
+	for _, name := range columns {
+		if name == "Close" {
+			hasClose = true
+		}
+		if barField(&Bar{}, name) == nil {
+			set.Extra[name] = []float64{}
+		}
+	}
+	if !hasClose {
+		return nil, fmt.Errorf("[%s] Data is missing columns [Close]", asset)
+	}
+	return &barBuilder{set, window}, nil
+}
+
+func (b *barBuilder) add(date int64, values []float64) {
+	if !b.window.contains(date) {
+		return
+	}
+	bar := Bar{Date: date, Open: math.NaN(), High: math.NaN(), Low: math.NaN(), Close: math.NaN(), Volume: math.NaN()}
+	for i, name := range b.set.Columns {
+		if field := barField(&bar, name); field != nil {
+			*field = values[i]
+		} else {
+			b.set.Extra[name] = append(b.set.Extra[name], values[i])
+		}
+	}
+	b.set.Bars = append(b.set.Bars, bar)
+}
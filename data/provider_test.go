@@ -0,0 +1,110 @@
+package data
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestLoadHash(t *testing.T) {
+	csv := []byte("Date,Open,High,Low,Close\n0,1,2,0.5,1.5\n3600,1.5,2,1,1.8\n")
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(csv)
+	w.Close()
+	jsonl := []byte("{\"Date\":0,\"Close\":1.5}\n{\"Date\":3600,\"Close\":1.8}\n")
+	dir := t.TempDir()
+	for _, test := range []struct {
+		fileName string
+		contents []byte
+	}{
+		{"bars.csv", csv},
+		{"bars.csv.gz", gz.Bytes()},
+		{"bars.jsonl", jsonl},
+	} {
+		fileName := filepath.Join(dir, test.fileName)
+		if err := os.WriteFile(fileName, test.contents, 0644); err != nil {
+			t.Fatal(err)
+		}
+		set, err := AutoProvider{}.Load("TEST", fileName, Window{})
+		if err != nil {
+			t.Fatalf("%s: %v", test.fileName, err)
+		}
+		sum := sha256.Sum256(test.contents)
+		if set.Hash != hex.EncodeToString(sum[:]) || len(set.Bars) != 2 || set.Bars[1].Close != 1.8 {
+			t.Errorf("%s: expected [2] bars and sha256 [%x] Received [%d] bars and [%s]", test.fileName, sum, len(set.Bars), set.Hash)
+		}
+	}
+}
+
+type parquetTestBar struct {
+	Date  int64   `parquet:"Date"`
+	Close float64 `parquet:"Close"`
+}
+
+func TestLoadWindow(t *testing.T) {
+	dir := t.TempDir()
+	csv, jsonl := "Date,Close\n", ""
+	parquetBars := []parquetTestBar{}
+	for i := 0; i < 10; i++ {
+		csv += fmt.Sprintf("%d,%d\n", i*3600, i)
+		jsonl += fmt.Sprintf("{\"Date\":%d,\"Close\":%d}\n", i*3600, i)
+		parquetBars = append(parquetBars, parquetTestBar{int64(i * 3600), float64(i)})
+	}
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(csv))
+	w.Close()
+	files := map[string][]byte{"bars.csv": []byte(csv), "bars.csv.gz": gz.Bytes(), "bars.jsonl": []byte(jsonl)}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), contents, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := parquet.WriteFile(filepath.Join(dir, "bars.parquet"), parquetBars); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		provider string
+		fileName string
+	}{
+		{"csv", "bars.csv"},
+		{"csv.gz", "bars.csv.gz"},
+		{"jsonl", "bars.jsonl"},
+		{"parquet", "bars.parquet"},
+	} {
+		provider, err := GetProvider(test.provider)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, window := range []struct {
+			window   Window
+			expected string
+		}{
+			{Window{}, "[0 1 2 3 4 5 6 7 8 9]"},
+			{Window{Start: 2 * 3600, End: 5 * 3600}, "[2 3 4 5]"},
+			{Window{Start: 7 * 3600}, "[7 8 9]"},
+			{Window{End: 3600}, "[0 1]"},
+			{Window{Start: 20 * 3600}, "[]"},
+		} {
+			set, err := provider.Load("TEST", filepath.Join(dir, test.fileName), window.window)
+			if err != nil {
+				t.Fatalf("%s: %v", test.fileName, err)
+			}
+			closes := []float64{}
+			for _, bar := range set.Bars {
+				closes = append(closes, bar.Close)
+			}
+			if fmt.Sprint(closes) != window.expected {
+				t.Errorf("%s %+v: expected %s Received %v", test.fileName, window.window, window.expected, closes)
+			}
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package data
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/go-gota/gota/dataframe"
+)
+
+/*
+Data quality checks:
+
+	non-monotonic: a row's Date is earlier than the previous row's
+	duplicates:    more than one row with the same Date
+	gaps:          consecutive Dates further apart than the bar interval while the market is open
+	nan/zero:      price columns (Open, High, Low, Close) that are NaN or <= 0
+	spikes:        Close moves more than the spike threshold from the previous Close and the next Close returns
+	               within it (a lasting move such as a split or a crash is not a spike)
+
+Policies:
+
+	fail:  return a *QualityError if any issue is found
+	warn:  report issues and leave the data unchanged
+	ffill: drop duplicates, fill bad prices/spikes with the previous value, insert missing bars as copies of the previous bar
+	drop:  drop duplicates and rows with bad prices or spikes (gaps are reported only)
+*/
+
+var Policies = []string{"fail", "warn", "ffill", "drop"}
+
+var priceColumns = []string{"Open", "High", "Low", "Close"}
+
+const maxSamples = 10
+
+type QualityConfig struct {
+	Policy          string             // fail, warn, ffill or drop
+	IntervalSeconds int64              // Expected seconds between bars
+	SpikeThreshold  float64            // Absolute Close return considered a spike (0.5 = 50%)
+	IsOpen          func(t int64) bool // Whether a bar is expected at t (nil if the market never closes)
+}
+
+type QualityReport struct {
+	Asset        string
+	DataFile     string
+	Policy       string
+	Rows         int      // Rows before the policy was applied
+	RowsAfter    int      // Rows after the policy was applied
+	NonMonotonic int      // Rows earlier than the previous row
+	Duplicates   int      // Rows sharing a Date with a previous row
+	Gaps         int      // Number of gaps between bars
+	MissingBars  int      // Number of bars missing in all gaps
+	NaNPrices    int      // Price values that are NaN (or not a number)
+	ZeroPrices   int      // Price values <= 0
+	Spikes       int      // Close moves larger than the spike threshold that revert on the next bar
+	Samples      []string // First issues found
+}
+
+type QualityError struct {
+	Report QualityReport
+}
+
+func (e *QualityError) Error() string {
+	return fmt.Sprintf("[%s] Data quality check failed: %s", e.Report.Asset, e.Report.Summary())
+}
+
+func IsValidPolicy(policy string) bool {
+	for _, p := range Policies {
+		if p == policy {
+			return true
+		}
+	}
+	return false
+}
+
+func (r QualityReport) HasIssues() bool {
+	return r.NonMonotonic+r.Duplicates+r.Gaps+r.NaNPrices+r.ZeroPrices+r.Spikes > 0
+}
+
+func (r QualityReport) Summary() string {
+	return fmt.Sprintf("non-monotonic %d, duplicates %d, gaps %d (%d missing bars), NaN prices %d, zero prices %d, spikes %d",
+		r.NonMonotonic, r.Duplicates, r.Gaps, r.MissingBars, r.NaNPrices, r.ZeroPrices, r.Spikes)
+}
+
+func (r QualityReport) String() string {
+	str := fmt.Sprintf("Asset %s\n", r.Asset)
+	str += fmt.Sprintf("DataFile %s\n", r.DataFile)
+	str += fmt.Sprintf("Policy %s\n", r.Policy)
+	str += fmt.Sprintf("Rows %d\n", r.Rows)
+	str += fmt.Sprintf("RowsAfterPolicy %d\n", r.RowsAfter)
+	str += fmt.Sprintf("NonMonotonic %d\n", r.NonMonotonic)
+	str += fmt.Sprintf("Duplicates %d\n", r.Duplicates)
+	str += fmt.Sprintf("Gaps %d\n", r.Gaps)
+	str += fmt.Sprintf("MissingBars %d\n", r.MissingBars)
+	str += fmt.Sprintf("NaNPrices %d\n", r.NaNPrices)
+	str += fmt.Sprintf("ZeroPrices %d\n", r.ZeroPrices)
+	str += fmt.Sprintf("Spikes %d\n", r.Spikes)
+	for _, sample := range r.Samples {
+		str += fmt.Sprintf("Issue %s\n", sample)
+	}
+	return str
+}
+
+type qualityRow struct {
+	date   int64
+	fields []string
+}
+
+/*
+Checks dataFrame and applies cfg.Policy, the returned DataFrame is sorted by Date
+*/
+func CheckQuality(asset string, dataFile string, dataFrame dataframe.DataFrame, cfg QualityConfig) (dataframe.DataFrame, QualityReport, error) {
+	report := QualityReport{Asset: asset, DataFile: dataFile, Policy: cfg.Policy, Rows: dataFrame.Nrow()}
+	records := dataFrame.Records()
+	if len(records) < 2 {
+		report.RowsAfter = report.Rows
+		return dataFrame, report, nil
+	}
+	header := records[0]
+	dateCol := -1
+	priceCols := []int{}
+	closeCol := -1
+	for i, name := range header {
+		if name == "Date" {
+			dateCol = i
+		}
+		if name == "Close" {
+			closeCol = i
+		}
+		for _, p := range priceColumns {
+			if name == p {
+				priceCols = append(priceCols, i)
+			}
+		}
+	}
+	if dateCol < 0 || closeCol < 0 {
+		return dataFrame, report, fmt.Errorf("[%s] Data quality check requires Date and Close columns", asset)
+	}
+	sample := func(format string, a ...interface{}) {
+		if len(report.Samples) < maxSamples {
+			report.Samples = append(report.Samples, fmt.Sprintf(format, a...))
+		}
+	}
+	rows := make([]qualityRow, 0, len(records)-1)
+	for i, rec := range records[1:] {
+		date, err := strconv.ParseInt(rec[dateCol], 10, 64)
+		if err != nil {
+			return dataFrame, report, fmt.Errorf("[%s] Invalid Date [%s] on row %d", asset, rec[dateCol], i)
+		}
+		if len(rows) > 0 && date < rows[len(rows)-1].date {
+			report.NonMonotonic += 1
+			sample("non-monotonic Date %d after %d (row %d)", date, rows[len(rows)-1].date, i)
+		}
+		rows = append(rows, qualityRow{date, append([]string{}, rec...)})
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].date < rows[j].date
+	})
+	fix := cfg.Policy == "ffill" || cfg.Policy == "drop"
+	closes := make([]float64, len(rows))
+	for i, row := range rows {
+		closes[i] = math.NaN()
+		if v, err := strconv.ParseFloat(row.fields[closeCol], 64); err == nil && v > 0.0 {
+			closes[i] = v
+		}
+	}
+	cleaned := make([]qualityRow, 0, len(rows))
+	lastValid := make([]string, len(header))
+	for i, row := range rows {
+		if len(cleaned) > 0 && row.date == cleaned[len(cleaned)-1].date {
+			report.Duplicates += 1
+			sample("duplicate Date %d", row.date)
+			if fix {
+				continue
+			}
+		}
+		bad := false
+		for _, col := range priceCols {
+			v, err := strconv.ParseFloat(row.fields[col], 64)
+			switch {
+			case err != nil || math.IsNaN(v):
+				report.NaNPrices += 1
+				sample("NaN %s at Date %d", header[col], row.date)
+			case v <= 0.0:
+				report.ZeroPrices += 1
+				sample("%s %g at Date %d", header[col], v, row.date)
+			default:
+				continue
+			}
+			bad = true
+			if cfg.Policy == "ffill" && lastValid[col] != "" {
+				row.fields[col] = lastValid[col]
+			}
+		}
+		if prev, ok := isSpike(rows, closes, i, cfg.SpikeThreshold); ok {
+			report.Spikes += 1
+			sample("spike Close %g after %g at Date %d", closes[i], prev, row.date)
+			bad = true
+			if cfg.Policy == "ffill" {
+				for _, col := range priceCols {
+					if lastValid[col] != "" {
+						row.fields[col] = lastValid[col]
+					}
+				}
+			}
+		}
+		if bad && cfg.Policy == "drop" {
+			continue
+		}
+		if len(cleaned) > 0 && cfg.IntervalSeconds > 0 {
+			prev := cleaned[len(cleaned)-1]
+			missing := getMissingBars(prev.date, row.date, cfg)
+			if len(missing) > 0 {
+				report.Gaps += 1
+				report.MissingBars += len(missing)
+				sample("gap of %d bars after Date %d", len(missing), prev.date)
+				if cfg.Policy == "ffill" {
+					for _, date := range missing {
+						filled := append([]string{}, prev.fields...)
+						filled[dateCol] = strconv.FormatInt(date, 10)
+						cleaned = append(cleaned, qualityRow{date, filled})
+					}
+				}
+			}
+		}
+		for _, col := range priceCols {
+			v, err := strconv.ParseFloat(row.fields[col], 64)
+			if err == nil && !math.IsNaN(v) && v > 0.0 {
+				lastValid[col] = row.fields[col]
+			}
+		}
+		cleaned = append(cleaned, row)
+	}
+	if cfg.Policy == "fail" && report.HasIssues() {
+		return dataFrame, report, &QualityError{report}
+	}
+	if !fix {
+		report.RowsAfter = report.Rows
+		return dataFrame.Arrange(dataframe.Sort("Date")), report, nil
+	}
+	out := make([][]string, 0, len(cleaned)+1)
+	out = append(out, header)
+	for _, row := range cleaned {
+		out = append(out, row.fields)
+	}
+	fixed := dataframe.LoadRecords(out)
+	if fixed.Err != nil {
+		return dataFrame, report, fixed.Err
+	}
+	report.RowsAfter = fixed.Nrow()
+	return fixed, report, nil
+}
+
+/*
+Whether the Close of rows[i] moves more than threshold from the previous valid Close and the next valid Close
+returns within threshold of it, the previous Close is returned. Closes are the raw values so a lasting move
+is compared against the prices before it, not against the ones a policy kept.
+*/
+func isSpike(rows []qualityRow, closes []float64, i int, threshold float64) (float64, bool) {
+	if math.IsNaN(closes[i]) {
+		return 0.0, false
+	}
+	prev, next := math.NaN(), math.NaN()
+	for j := i - 1; j >= 0 && math.IsNaN(prev); j-- {
+		if rows[j].date < rows[i].date {
+			prev = closes[j]
+		}
+	}
+	for j := i + 1; j < len(rows) && math.IsNaN(next); j++ {
+		if rows[j].date > rows[i].date {
+			next = closes[j]
+		}
+	}
+	if math.IsNaN(prev) || math.IsNaN(next) {
+		return 0.0, false
+	}
+	return prev, math.Abs(closes[i]/prev-1) > threshold && math.Abs(next/prev-1) <= threshold
+}
+
+/*
+Dates of the bars expected strictly between prev and next
+*/
+func getMissingBars(prev int64, next int64, cfg QualityConfig) []int64 {
+	missing := []int64{}
+	for date := prev + cfg.IntervalSeconds; date < next; date += cfg.IntervalSeconds {
+		if cfg.IsOpen == nil || cfg.IsOpen(date) {
+			missing = append(missing, date)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,100 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-gota/gota/dataframe"
+)
+
+/*
+Hourly bars with Open = High = Low = Close, a row per close
+*/
+func newTestFrame(t *testing.T, dates []int64, closes []string) dataframe.DataFrame {
+	t.Helper()
+	records := [][]string{{"Date", "Open", "High", "Low", "Close"}}
+	for i, c := range closes {
+		records = append(records, []string{fmt.Sprint(dates[i]), c, c, c, c})
+	}
+	dataFrame := dataframe.LoadRecords(records)
+	if dataFrame.Err != nil {
+		t.Fatal(dataFrame.Err)
+	}
+	return dataFrame
+}
+
+func hourly(n int) []int64 {
+	dates := make([]int64, n)
+	for i := range dates {
+		dates[i] = 1672531200 + int64(i)*3600
+	}
+	return dates
+}
+
+func TestCheckQuality(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		dates  []int64
+		closes []string
+		policy string
+		report QualityReport // Counts only
+		after  []string      // Closes after the policy
+	}{
+		{"Clean", hourly(4), []string{"100", "101", "102", "103"}, "drop", QualityReport{}, []string{"100", "101", "102", "103"}},
+		{"Spike reverting on the next bar (drop)", hourly(5), []string{"100", "100", "300", "101", "100"}, "drop", QualityReport{Gaps: 1, MissingBars: 1, Spikes: 1}, []string{"100", "100", "101", "100"}},
+		{"Spike reverting on the next bar (ffill)", hourly(5), []string{"100", "100", "300", "101", "100"}, "ffill", QualityReport{Spikes: 1}, []string{"100", "100", "100", "101", "100"}},
+		{"Crash is a level shift", hourly(5), []string{"100", "100", "40", "41", "40"}, "drop", QualityReport{}, []string{"100", "100", "40", "41", "40"}},
+		{"Split is a level shift", hourly(5), []string{"100", "100", "300", "301", "302"}, "ffill", QualityReport{}, []string{"100", "100", "300", "301", "302"}},
+		{"Spike after a level shift", hourly(6), []string{"100", "300", "301", "10", "302", "303"}, "drop", QualityReport{Gaps: 1, MissingBars: 1, Spikes: 1}, []string{"100", "300", "301", "302", "303"}},
+		{"Last bar is not a spike", hourly(3), []string{"100", "100", "300"}, "drop", QualityReport{}, []string{"100", "100", "300"}},
+		{"Bad prices (ffill)", hourly(4), []string{"100", "NaN", "0", "103"}, "ffill", QualityReport{NaNPrices: 4, ZeroPrices: 4}, []string{"100", "100", "100", "103"}},
+		{"Duplicates and non-monotonic", []int64{0, 7200, 3600, 3600}, []string{"100", "102", "101", "101"}, "drop", QualityReport{NonMonotonic: 1, Duplicates: 1}, []string{"100", "101", "102"}},
+		{"Gap (ffill)", []int64{0, 3600, 14400}, []string{"100", "101", "102"}, "ffill", QualityReport{Gaps: 1, MissingBars: 2}, []string{"100", "101", "101", "101", "102"}},
+		{"Gap (warn)", []int64{0, 3600, 14400}, []string{"100", "101", "102"}, "warn", QualityReport{Gaps: 1, MissingBars: 2}, []string{"100", "101", "102"}},
+	} {
+		cfg := QualityConfig{Policy: test.policy, IntervalSeconds: 3600, SpikeThreshold: 0.5}
+		fixed, report, err := CheckQuality("TEST", "test.csv", newTestFrame(t, test.dates, test.closes), cfg)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		counts := QualityReport{NonMonotonic: report.NonMonotonic, Duplicates: report.Duplicates, Gaps: report.Gaps, MissingBars: report.MissingBars,
+			NaNPrices: report.NaNPrices, ZeroPrices: report.ZeroPrices, Spikes: report.Spikes}
+		if fmt.Sprint(counts) != fmt.Sprint(test.report) {
+			t.Errorf("%s: expected %s Received %s", test.name, test.report.Summary(), counts.Summary())
+		}
+		after := fixed.Col("Close").Records()
+		if fmt.Sprint(after) != fmt.Sprint(test.after) {
+			t.Errorf("%s: expected closes %v Received %v", test.name, test.after, after)
+		}
+		if report.RowsAfter != len(test.after) {
+			t.Errorf("%s: expected [%d] rows after the policy Received [%d]", test.name, len(test.after), report.RowsAfter)
+		}
+	}
+}
+
+func TestCheckQualityFail(t *testing.T) {
+	cfg := QualityConfig{Policy: "fail", IntervalSeconds: 3600, SpikeThreshold: 0.5}
+	_, _, err := CheckQuality("TEST", "test.csv", newTestFrame(t, hourly(4), []string{"100", "100", "300", "100"}), cfg)
+	qualityErr := &QualityError{}
+	if !errors.As(err, &qualityErr) || qualityErr.Report.Spikes != 1 {
+		t.Fatalf("Expected a QualityError with [1] spike Received %v", err)
+	}
+	_, _, err = CheckQuality("TEST", "test.csv", newTestFrame(t, hourly(4), []string{"100", "100", "300", "300"}), cfg)
+	if err != nil {
+		t.Fatalf("Expected no error for a level shift Received %v", err)
+	}
+}
+
+func TestGetMissingBars(t *testing.T) {
+	// Closed every other hour
+	cfg := QualityConfig{IntervalSeconds: 3600, IsOpen: func(t int64) bool { return t%7200 == 0 }}
+	if missing := getMissingBars(0, 5*3600, cfg); fmt.Sprint(missing) != "[7200 14400]" {
+		t.Errorf("Expected [7200 14400] Received %v", missing)
+	}
+	cfg.IsOpen = nil
+	if missing := getMissingBars(0, 3600, cfg); len(missing) != 0 {
+		t.Errorf("Expected no missing bars Received %v", missing)
+	}
+}
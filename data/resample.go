@@ -0,0 +1,191 @@
+package data
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+)
+
+type Bar struct {
+	Date   int64 // Unix seconds at the start of the bar
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+/*
+Aggregates dataFrame into bars of intervalSeconds aligned to midnight in loc (daily bars start at local midnight):
+
+	Open:   first Open (first Close if there is no Open column)
+	High:   highest High (highest Close if there is no High column)
+	Low:    lowest Low (lowest Close if there is no Low column)
+	Close:  last Close
+	Volume: sum of Volume (only if there is a Volume column)
+
+Every EMA_<n> column of dataFrame is recomputed along with MACD, SIGNAL, dP, SAR and CHAI (see indicators.go),
+other columns are dropped. NaN values are skipped.
+*/
+func Resample(dataFrame dataframe.DataFrame, intervalSeconds int64, loc *time.Location) (dataframe.DataFrame, error) {
+	if intervalSeconds <= 0 {
+		return dataframe.DataFrame{}, fmt.Errorf("Invalid resample interval [%d]", intervalSeconds)
+	}
+	names := dataFrame.Names()
+	dates, err := dataFrame.Col("Date").Int()
+	if err != nil {
+		return dataframe.DataFrame{}, err
+	}
+	closes := dataFrame.Col("Close").Float()
+	opens, highs, lows := closes, closes, closes
+	if hasColumn(names, "Open") {
+		opens = dataFrame.Col("Open").Float()
+	}
+	hasHighLow := hasColumn(names, "High") && hasColumn(names, "Low")
+	if hasHighLow {
+		highs = dataFrame.Col("High").Float()
+		lows = dataFrame.Col("Low").Float()
+	}
+	hasVolume := hasColumn(names, "Volume")
+	volumes := make([]float64, len(closes))
+	if hasVolume {
+		volumes = dataFrame.Col("Volume").Float()
+	}
+	order := make([]int, len(dates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return dates[order[a]] < dates[order[b]]
+	})
+	var bar Bar
+	bars := []Bar{}
+	for _, i := range order {
+		_, offset := time.Unix(int64(dates[i]), 0).In(loc).Zone()
+		start := int64(dates[i]) - mod(int64(dates[i])+int64(offset), intervalSeconds)
+		if len(bars) == 0 || bars[len(bars)-1].Date != start {
+			bar = Bar{Date: start, Open: math.NaN(), High: math.NaN(), Low: math.NaN(), Close: math.NaN()}
+			bars = append(bars, bar)
+		}
+		if math.IsNaN(bar.Open) && !math.IsNaN(opens[i]) {
+			bar.Open = opens[i]
+		}
+		if !math.IsNaN(highs[i]) && (math.IsNaN(bar.High) || highs[i] > bar.High) {
+			bar.High = highs[i]
+		}
+		if !math.IsNaN(lows[i]) && (math.IsNaN(bar.Low) || lows[i] < bar.Low) {
+			bar.Low = lows[i]
+		}
+		if !math.IsNaN(closes[i]) {
+			bar.Close = closes[i]
+		}
+		if !math.IsNaN(volumes[i]) {
+			bar.Volume += volumes[i]
+		}
+		bars[len(bars)-1] = bar
+	}
+	// Drop bars without a single valid Close
+	valid := bars[:0]
+	for _, b := range bars {
+		if !math.IsNaN(b.Close) {
+			if math.IsNaN(b.Open) {
+				b.Open = b.Close
+			}
+			valid = append(valid, b)
+		}
+	}
+	bars = valid
+	emas := []int{}
+	for _, name := range names {
+		if n, err := strconv.Atoi(strings.TrimPrefix(name, "EMA_")); err == nil && strings.HasPrefix(name, "EMA_") {
+			emas = append(emas, n)
+		}
+	}
+	return BarsToDataFrame(bars, emas, hasHighLow, hasVolume), nil
+}
+
+/*
+Builds a DataFrame of bars with the indicators read by the strategies, SAR requires withHighLow
+and CHAI requires withHighLow and withVolume
+*/
+func BarsToDataFrame(bars []Bar, emas []int, withHighLow bool, withVolume bool) dataframe.DataFrame {
+	dates := make([]int, len(bars))
+	opens := make([]float64, len(bars))
+	highs := make([]float64, len(bars))
+	lows := make([]float64, len(bars))
+	closes := make([]float64, len(bars))
+	volumes := make([]float64, len(bars))
+	for i, b := range bars {
+		dates[i] = int(b.Date)
+		opens[i] = b.Open
+		highs[i] = b.High
+		lows[i] = b.Low
+		closes[i] = b.Close
+		volumes[i] = b.Volume
+	}
+	columns := []series.Series{
+		series.New(dates, series.Int, "Date"),
+		series.New(opens, series.Float, "Open"),
+		series.New(highs, series.Float, "High"),
+		series.New(lows, series.Float, "Low"),
+		series.New(closes, series.Float, "Close"),
+	}
+	if withVolume {
+		columns = append(columns, series.New(volumes, series.Float, "Volume"))
+	}
+	for _, n := range emas {
+		columns = append(columns, series.New(EMA(closes, n), series.Float, fmt.Sprintf("EMA_%d", n)))
+	}
+	macd, signal := MACD(closes)
+	columns = append(columns,
+		series.New(macd, series.Float, "MACD"),
+		series.New(signal, series.Float, "SIGNAL"),
+		series.New(PriceChange(closes), series.Float, "dP"),
+	)
+	if withHighLow {
+		columns = append(columns, series.New(ParabolicSAR(highs, lows), series.Float, "SAR"))
+		if withVolume {
+			columns = append(columns, series.New(Chaikin(highs, lows, closes, volumes), series.Float, "CHAI"))
+		}
+	}
+	return dataframe.New(columns...)
+}
+
+/*
+Keeps the rows whose Date isOpen returns true for
+*/
+func FilterSessions(dataFrame dataframe.DataFrame, isOpen func(t int64) bool) (dataframe.DataFrame, error) {
+	dates, err := dataFrame.Col("Date").Int()
+	if err != nil {
+		return dataframe.DataFrame{}, err
+	}
+	indexes := make([]int, 0, len(dates))
+	for i, d := range dates {
+		if isOpen(int64(d)) {
+			indexes = append(indexes, i)
+		}
+	}
+	if len(indexes) == len(dates) {
+		return dataFrame, nil
+	}
+	return dataFrame.Subset(indexes), nil
+}
+
+func mod(a int64, b int64) int64 {
+	return ((a % b) + b) % b
+}
+
+func hasColumn(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package data
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-gota/gota/dataframe"
+)
+
+/*
+Hourly bars from 2023-01-01 00:00 UTC, Open = i, High = i + 0.5, Low = i - 0.5, Close = i + 0.25, Volume = 1
+*/
+func newTestOHLCV(t *testing.T, n int) dataframe.DataFrame {
+	t.Helper()
+	records := [][]string{{"Date", "Open", "High", "Low", "Close", "Volume", "EMA_3"}}
+	for i, date := range hourly(n) {
+		v := float64(i + 1)
+		records = append(records, []string{fmt.Sprint(date), fmt.Sprint(v), fmt.Sprint(v + 0.5), fmt.Sprint(v - 0.5), fmt.Sprint(v + 0.25), "1", "0"})
+	}
+	dataFrame := dataframe.LoadRecords(records)
+	if dataFrame.Err != nil {
+		t.Fatal(dataFrame.Err)
+	}
+	return dataFrame
+}
+
+/*
+Values of a column as printed by fmt, Date as integers
+*/
+func column(dataFrame dataframe.DataFrame, name string) string {
+	if name == "Date" {
+		return fmt.Sprint(dataFrame.Col(name).Records())
+	}
+	return fmt.Sprint(dataFrame.Col(name).Float())
+}
+
+func TestResample(t *testing.T) {
+	resampled, err := Resample(newTestOHLCV(t, 8), 4*3600, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		column string
+		values string
+	}{
+		{"Date", "[1672531200 1672545600]"},
+		{"Open", "[1 5]"},
+		{"High", "[4.5 8.5]"},
+		{"Low", "[0.5 4.5]"},
+		{"Close", "[4.25 8.25]"},
+		{"Volume", "[4 4]"},
+	} {
+		if values := column(resampled, test.column); values != test.values {
+			t.Errorf("%s: expected %s Received %s", test.column, test.values, values)
+		}
+	}
+	names := fmt.Sprint(resampled.Names())
+	if names != "[Date Open High Low Close Volume EMA_3 MACD SIGNAL dP SAR CHAI]" {
+		t.Errorf("Unexpected columns %s", names)
+	}
+	// EMA_3 is recomputed from the resampled closes
+	if ema := resampled.Col("EMA_3").Float(); ema[0] == 0.0 {
+		t.Errorf("Expected EMA_3 to be recomputed Received %v", ema)
+	}
+}
+
+func TestResampleUnsortedAndNaN(t *testing.T) {
+	records := [][]string{
+		{"Date", "Close"},
+		{"7200", "3"},
+		{"0", "1"},
+		{"3600", "NaN"},
+		{"14400", "NaN"},
+		{"10800", "4"},
+	}
+	resampled, err := Resample(dataframe.LoadRecords(records), 7200, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 0-7200: Close 1 (the NaN is skipped), 7200-14400: Open 3 Close 4, 14400-: only NaN, dropped
+	for name, values := range map[string]string{"Date": "[0 7200]", "Open": "[1 3]", "Close": "[1 4]", "High": "[1 4]", "Low": "[1 3]"} {
+		if received := column(resampled, name); received != values {
+			t.Errorf("%s: expected %s Received %s", name, values, received)
+		}
+	}
+}
+
+func TestResampleLocalMidnight(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	resampled, err := Resample(newTestOHLCV(t, 24), 86400, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2023-01-01 00:00 UTC is 19:00 on Dec 31 in New York
+	dec31 := time.Date(2022, time.December, 31, 0, 0, 0, 0, loc).Unix()
+	jan1 := time.Date(2023, time.January, 1, 0, 0, 0, 0, loc).Unix()
+	if dates := column(resampled, "Date"); dates != fmt.Sprintf("[%d %d]", dec31, jan1) {
+		t.Errorf("Expected daily bars at [%d %d] Received %s", dec31, jan1, dates)
+	}
+	if volumes := column(resampled, "Volume"); volumes != "[5 19]" {
+		t.Errorf("Expected volumes [5 19] Received %s", volumes)
+	}
+}
+
+func TestResampleInvalidInterval(t *testing.T) {
+	if _, err := Resample(newTestOHLCV(t, 2), 0, time.UTC); err == nil {
+		t.Error("Expected an error for a zero interval")
+	}
+}
+
+func TestDetectInterval(t *testing.T) {
+	for _, test := range []struct {
+		dates    []int64
+		interval int64
+	}{
+		{hourly(5), 3600},
+		{[]int64{0, 3600, 3600, 10800, 14400}, 3600},
+		{[]int64{0, 0}, 0},
+	} {
+		closes := make([]string, len(test.dates))
+		for i := range closes {
+			closes[i] = "1"
+		}
+		interval, err := DetectInterval(newTestFrame(t, test.dates, closes))
+		if err != nil || interval != test.interval {
+			t.Errorf("%v: expected [%d] Received [%d] %v", test.dates, test.interval, interval, err)
+		}
+	}
+}
+
+func TestFilterSessions(t *testing.T) {
+	dataFrame := newTestFrame(t, hourly(6), []string{"1", "2", "3", "4", "5", "6"})
+	filtered, err := FilterSessions(dataFrame, func(t int64) bool { return t%7200 == 0 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if closes := column(filtered, "Close"); closes != "[1 3 5]" {
+		t.Errorf("Expected closes [1 3 5] Received %s", closes)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/ini.v1"
+)
+
+/*
+Data file of each asset, [DataSources] <asset> = <source>:
+
+	path:      <data_dir>/<asset>/<path> (or an absolute path)
+	glob:      last match (sorted by name) of <data_dir>/<asset>/<glob> (or an absolute glob)
+	tag:<tag>: the only file in <data_dir>/<asset>/ named <tag>.<ext> or <name>_<tag>.<ext>
+
+An asset without a source is an error. Modification times are never used, touching a file must not change results.
+*/
+
+type dataHash struct {
+	size    int64
+	modTime int64
+	sum     string
+}
+
+var dataSources map[string]string
+var dataHashes = map[string]dataHash{}
+var dataHashMut sync.Mutex
+
+func getDataSources(confFile string) (map[string]string, error) {
+	cfg, err := ini.Load(confFile)
+	if err != nil {
+		fmt.Printf("Failed to read %v\n", err)
+		return nil, err
+	}
+	sources := map[string]string{}
+	for _, key := range cfg.Section("DataSources").Keys() {
+		sources[key.Name()] = key.String()
+	}
+	return sources, nil
+}
+
+/*
+Resolves the data file of asset (see [DataSources]), must be called with dataFileMut held
+*/
+func getDataFile(asset string, dataDir string) (string, error) {
+	assetDir := fmt.Sprintf("%s/%s/", dataDir, asset)
+	source, ok := dataSources[asset]
+	if !ok || source == "" {
+		return "", fmt.Errorf("[%s] No data source configured in [DataSources] (a path, glob or tag:<tag>)", asset)
+	}
+	if source == "newest" {
+		return "", fmt.Errorf("[%s] [DataSources] newest (most recently modified file) is no longer supported, use a glob (last match by name) or tag:<tag>", asset)
+	}
+	if tag, isTag := strings.CutPrefix(source, "tag:"); isTag {
+		return getTaggedFile(asset, assetDir, tag)
+	}
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(assetDir, source)
+	}
+	if strings.ContainsAny(source, "*?[") {
+		matches, err := filepath.Glob(source)
+		if err != nil {
+			return "", err
+		}
+		if len(matches) == 0 {
+			return "", fmt.Errorf("[%s] No data file matches [%s]", asset, source)
+		}
+		sort.Strings(matches)
+		return matches[len(matches)-1], nil
+	}
+	if _, err := os.Stat(source); err != nil {
+		return "", err
+	}
+	return source, nil
+}
+
+func getTaggedFile(asset string, assetDir string, tag string) (string, error) {
+	entries, err := os.ReadDir(assetDir)
+	if err != nil {
+		return "", err
+	}
+	matches := []string{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := strings.SplitN(e.Name(), ".", 2)[0]
+		if name == tag || strings.HasSuffix(name, "_"+tag) {
+			matches = append(matches, filepath.Join(assetDir, e.Name()))
+		}
+	}
+	if len(matches) != 1 {
+		return "", fmt.Errorf("[%s] Expected [1] data file tagged [%s] Received [%d]", asset, tag, len(matches))
+	}
+	return matches[0], nil
+}
+
+/*
+sha256 of the contents of dataFile, cached until the file's size or modification time changes.
+Simulations use the hash of the bytes their data was loaded from instead (see readData).
+*/
+func getDataHash(dataFile string) (string, error) {
+	fi, err := os.Stat(dataFile)
+	if err != nil {
+		return "", err
+	}
+	dataHashMut.Lock()
+	defer dataHashMut.Unlock()
+	if h, ok := dataHashes[dataFile]; ok && h.size == fi.Size() && h.modTime == fi.ModTime().UnixNano() {
+		return h.sum, nil
+	}
+	f, err := os.Open(dataFile)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	dataHashes[dataFile] = dataHash{fi.Size(), fi.ModTime().UnixNano(), sum}
+	return sum, nil
+}
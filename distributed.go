@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cheggaaa/pb"
+	"github.com/fatih/color"
+	"gopkg.in/ini.v1"
+
+	"Simulations_v5/calendar"
+	"Simulations_v5/cluster"
+	"Simulations_v5/results"
+	"Simulations_v5/simulation"
+)
+
+/*
+Sweep distributed over several machines (see cluster):
+
+	coordinator [-resume <run>] [-addr host:port]: enumerates the jobs of the sweep and saves results as workers send them
+	worker [-coordinator url] [-parallel n] [-name name]: runs jobs leased from the coordinator
+
+Workers read data from their own data_dir and must share the coordinator's runSettings. A result whose data hash
+differs from the coordinator's data file is recorded as a failure. To try it locally start a coordinator and a few
+workers with the same conf.ini.
+*/
+
+type clusterParams struct {
+	addr         string        // Address the coordinator listens on
+	coordinator  string        // URL of the coordinator workers connect to
+	leaseTimeout time.Duration // Time without a heartbeat before a job is handed to another worker
+	maxAttempts  int           // Leases of a job before it is recorded as failed
+	parallel     int           // Simulations a worker runs at once
+	maxRetries   int           // Retries of a request that fails to reach the coordinator
+}
+
+/*
+Settings that change the result of a simulation besides its parameters and data
+*/
+type runSettings struct {
+	StartDate      string  `json:"start_date"`
+	EndDate        string  `json:"end_date"`
+	Interval       int64   `json:"interval"`
+	Timezone       string  `json:"timezone"`
+	Calendar       string  `json:"calendar"`
+	SessionStart   string  `json:"session_start"`
+	SessionEnd     string  `json:"session_end"`
+	Holidays       string  `json:"holidays"` // Configured holidays, sorted and comma separated
+	InvestAmt      float64 `json:"invest_amt"`
+	TaxRate        float64 `json:"tax_rate"`
+	Fees           float64 `json:"fees"`
+	SellCondition  int     `json:"sell_condition"`
+	QualityPolicy  string  `json:"quality_policy"`
+	SpikeThreshold float64 `json:"spike_threshold"`
+}
+
+func getRunSettings() runSettings {
+	cal, sessionStart, sessionEnd, holidays := "crypto", "", "", ""
+	if equity, ok := tradingCalendar.(calendar.Equity); ok {
+		dates := []string{}
+		for date := range equity.Holidays {
+			dates = append(dates, date)
+		}
+		sort.Strings(dates)
+		cal, sessionStart, sessionEnd, holidays = "equity", equity.SessionStart.String(), equity.SessionEnd.String(), strings.Join(dates, ",")
+	}
+	return runSettings{startDate, endDate, barInterval, location.String(), cal, sessionStart, sessionEnd, holidays,
+		investmentAMT, taxRate, fees, sellCondition, qualityConf.Policy, qualityConf.SpikeThreshold}
+}
+
+func runCoordinator(ctx context.Context, confFile string, args []string) error {
+	params, err := getClusterParams(confFile)
+	if err != nil {
+		return err
+	}
+	flags := flag.NewFlagSet("coordinator", flag.ContinueOnError)
+	resume := flags.String("resume", "", "name of an interrupted run to continue")
+	addr := flags.String("addr", params.addr, "address to listen on")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	start := time.Now()
+	outFileName, manifest, err := startRun(confFile, *resume, start)
+	if err != nil {
+		return err
+	}
+	defer resultStore.Close()
+	jobs := []cluster.Job{}
+	jobParams := map[int]ParamSet{}
+	for _, asset := range assets {
+		for _, p := range getParamGrid() {
+			if runCheckpoint.isDone(jobKey(asset, p)) {
+				continue
+			}
+			id := len(jobs)
+			jobs = append(jobs, cluster.Job{ID: id, Asset: asset, Strategy: p.Strategy, EMA: p.EMA, ReinvestPerc: p.ReinvestPerc,
+				MinReturn: p.MinReturn, PercentDrop: p.PercentDrop, BalanceTrip: p.BalanceTrip})
+			jobParams[id] = p
+		}
+	}
+	settings, err := json.Marshal(getRunSettings())
+	if err != nil {
+		return err
+	}
+	coordinator := cluster.NewCoordinator(cluster.RunInfo{Run: outFileName, Settings: settings}, jobs, params.leaseTimeout, params.maxAttempts)
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: coordinator.Handler()}
+	go server.Serve(listener)
+	defer server.Close()
+	color.Green("Coordinating %d simulations on http://%s", len(jobs), listener.Addr())
+	bar = *pb.New(numSims)
+	bar.Start()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	received := 0
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			color.Yellow("\nInterrupted, %d leased simulations are abandoned", coordinator.Leased())
+			numCancelled = len(jobs) - received
+			break loop
+		case <-ticker.C:
+			coordinator.Reap()
+		case res, ok := <-coordinator.Results():
+			if !ok {
+				break loop
+			}
+			received += 1
+			r := checkWorkerResult(res, manifest)
+			p := jobParams[res.JobID]
+			logResult(r, p)
+			recordTrial(r, p)
+		}
+	}
+	bar.Finish()
+	return finishRun(ctx, "coordinator", outFileName, manifest, start)
+}
+
+/*
+Result of a worker, a result computed from a different data file than the coordinator's is turned into a failure
+*/
+func checkWorkerResult(res cluster.JobResult, manifest *runManifest) simulation.Result {
+	r := res.Result()
+	if r.Err != nil {
+		r.Err = fmt.Errorf("Worker [%s]: %w", res.Worker, r.Err)
+		return r
+	}
+	rec, err := results.ParseLine(r.ResultString)
+	if err != nil {
+		r.ResultString = ""
+		r.Err = fmt.Errorf("Worker [%s]: %w", res.Worker, err)
+		return r
+	}
+	if dataFile, ok := manifest.getDataFile(r.AssetName); ok && dataFile.Hash != "" && rec.DataHash != dataFile.Hash {
+		r.ResultString = ""
+		r.Err = fmt.Errorf("[%s] Worker [%s] data file hash [%s] differs from the coordinator's [%s]", r.AssetName, res.Worker, rec.DataHash, dataFile.Hash)
+	}
+	return r
+}
+
+func runWorker(ctx context.Context, confFile string, args []string) error {
+	params, err := getClusterParams(confFile)
+	if err != nil {
+		return err
+	}
+	hostname, _ := os.Hostname()
+	flags := flag.NewFlagSet("worker", flag.ContinueOnError)
+	coordinatorURL := flags.String("coordinator", params.coordinator, "URL of the coordinator")
+	parallel := flags.Int("parallel", params.parallel, "simulations run at once")
+	name := flags.String("name", fmt.Sprintf("%s-%d", hostname, os.Getpid()), "name of the worker")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *parallel < 1 {
+		return errors.New("-parallel must be at least 1")
+	}
+	worker := cluster.NewWorker(strings.TrimSuffix(*coordinatorURL, "/"), *name, params.maxRetries)
+	info, err := worker.RunInfo(ctx)
+	if err != nil {
+		return err
+	}
+	settings := runSettings{}
+	if err := json.Unmarshal(info.Settings, &settings); err != nil {
+		return err
+	}
+	if settings != getRunSettings() {
+		return fmt.Errorf("Worker settings differ from run [%s]: coordinator %+v worker %+v", info.Run, settings, getRunSettings())
+	}
+	color.Green("Worker [%s] running simulations of run [%s] from %s (%d at once)", *name, info.Run, *coordinatorURL, *parallel)
+	start := time.Now()
+	var countMut sync.Mutex
+	count := 0
+	err = worker.Run(ctx, *parallel, func(ctx context.Context, job cluster.Job) simulation.Result {
+		p := ParamSet{job.Strategy, job.EMA, job.ReinvestPerc, job.MinReturn, job.PercentDrop, job.BalanceTrip}
+		logFile, err := getLogFile(job.Asset, p)
+		if err != nil {
+			return simulation.Result{AssetName: job.Asset, Err: err}
+		}
+		r := runJob(ctx, job.Asset, p, logFile, dataDir)
+		if errors.Is(r.Err, context.Canceled) {
+			return r
+		}
+		if r.Err != nil {
+			fmt.Println(r.Err)
+		}
+		countMut.Lock()
+		count += 1
+		countMut.Unlock()
+		return r
+	})
+	if ctx.Err() != nil {
+		return fmt.Errorf("Worker [%s] interrupted, its leased simulations will be handed to other workers: %w", *name, ctx.Err())
+	}
+	if err != nil {
+		return err
+	}
+	color.Cyan("Done in %v, ran %d simulations", time.Since(start), count)
+	return nil
+}
+
+/*
+[Cluster] addr (default :8091), coordinator (default http://127.0.0.1:8091), lease_timeout in seconds (default 60),
+max_attempts (default 3), workers (simulations per worker, default the number of CPUs) and max_retries (default 5)
+*/
+func getClusterParams(confFile string) (clusterParams, error) {
+	cfg, err := ini.Load(confFile)
+	if err != nil {
+		fmt.Printf("Failed to read %v\n", err)
+		return clusterParams{}, err
+	}
+	section := cfg.Section("Cluster")
+	params := clusterParams{
+		addr:         section.Key("addr").MustString(":8091"),
+		coordinator:  section.Key("coordinator").MustString("http://127.0.0.1:8091"),
+		leaseTimeout: time.Duration(section.Key("lease_timeout").MustInt(60)) * time.Second,
+		maxAttempts:  section.Key("max_attempts").MustInt(3),
+		parallel:     section.Key("workers").MustInt(runtime.NumCPU()),
+		maxRetries:   section.Key("max_retries").MustInt(5),
+	}
+	if params.leaseTimeout < time.Second {
+		return clusterParams{}, errors.New("Config file not configured for [lease_timeout]")
+	}
+	if params.maxAttempts < 1 {
+		return clusterParams{}, errors.New("Config file not configured for [max_attempts]")
+	}
+	return params, nil
+}
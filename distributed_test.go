@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRunSettingsCalendar(t *testing.T) {
+	settings := func(extra string) runSettings {
+		writeTestConfig(t, extra)
+		return getRunSettings()
+	}
+	equity := settings("[Simulation]\ncalendar = equity\nholidays = 26Dec2022,02Jan2023\n")
+	if reordered := settings("[Simulation]\ncalendar = equity\nholidays = 02Jan2023, 26Dec2022\n"); reordered != equity {
+		t.Errorf("Same holidays in another order: expected %+v Received %+v", equity, reordered)
+	}
+	for name, extra := range map[string]string{
+		"crypto":        "",
+		"session_start": "[Simulation]\ncalendar = equity\nholidays = 26Dec2022,02Jan2023\nsession_start = 10:00\n",
+		"session_end":   "[Simulation]\ncalendar = equity\nholidays = 26Dec2022,02Jan2023\nsession_end = 15:00\n",
+		"holidays":      "[Simulation]\ncalendar = equity\nholidays = 26Dec2022\n",
+	} {
+		if s := settings(extra); s == equity {
+			t.Errorf("%s: expected settings to differ from %+v", name, equity)
+		}
+	}
+}
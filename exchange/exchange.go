@@ -0,0 +1,61 @@
+package exchange
+
+import (
+	"fmt"
+)
+
+/*
+Candle API shared by the HTTP client and the mock server:
+
+	GET <base_url>/api/v1/candles?symbol=<symbol>&interval=<interval>&start=<unix>&end=<unix>&limit=<n>
+
+Returns a JSON array of up to limit closed candles (see Candle) with start <= time and time + interval <= end + 1,
+oldest first.
+A 429 response carries a Retry-After header (seconds).
+*/
+
+const CandlesPath = "/api/v1/candles"
+
+type Candle struct {
+	Time   int64   `json:"time"` // Unix seconds at the start of the candle
+	Open   float64 `json:"open"`
+	High   float64 `json:"high"`
+	Low    float64 `json:"low"`
+	Close  float64 `json:"close"`
+	Volume float64 `json:"volume"`
+}
+
+type Client interface {
+	Candles(symbol string, interval string, start int64, end int64, limit int) ([]Candle, error)
+}
+
+type RateLimitError struct {
+	Retries int
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("Rate limited after [%d] retries", e.Retries)
+}
+
+/*
+Downloads every candle of symbol between start and end (inclusive), limit candles per request
+*/
+func FetchAll(client Client, symbol string, interval string, intervalSeconds int64, start int64, end int64, limit int) ([]Candle, error) {
+	candles := []Candle{}
+	for start <= end {
+		batch, err := client.Candles(symbol, interval, start, end, limit)
+		if err != nil {
+			return candles, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		for _, c := range batch {
+			if len(candles) == 0 || c.Time > candles[len(candles)-1].Time {
+				candles = append(candles, c)
+			}
+		}
+		start = batch[len(batch)-1].Time + intervalSeconds
+	}
+	return candles, nil
+}
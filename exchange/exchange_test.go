@@ -0,0 +1,84 @@
+package exchange
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFetchAllMock(t *testing.T) {
+	server := httptest.NewServer(NewMockServer(0).Handler())
+	defer server.Close()
+	client := NewHTTPClient(server.URL, 0, 0)
+	end := time.Now().Unix() / 3600 * 3600
+	start := end - 10*24*3600
+	// 240 candles in batches of 100
+	candles, err := FetchAll(client, "BTC", "1h", 3600, start, end-1, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 240 {
+		t.Fatalf("Expected [240] candles Received [%d]", len(candles))
+	}
+	for i, c := range candles {
+		if c.Time != start+int64(i)*3600 {
+			t.Fatalf("Candle %d: expected time [%d] Received [%d]", i, start+int64(i)*3600, c.Time)
+		}
+		if c.Low > c.Open || c.Low > c.Close || c.High < c.Open || c.High < c.Close {
+			t.Fatalf("Candle %d: inconsistent %+v", i, c)
+		}
+	}
+	again, err := client.Candles("BTC", "1h", start, start+3599, 10)
+	if err != nil || len(again) != 1 || again[0] != candles[0] {
+		t.Errorf("Expected the same first candle %+v Received %+v %v", candles[0], again, err)
+	}
+}
+
+/*
+Answers 429 (Retry-After: 0) to the first limited requests
+*/
+func newLimitedServer(limited int) *httptest.Server {
+	var mut sync.Mutex
+	requests := 0
+	mock := NewMockServer(0).Handler()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mut.Lock()
+		requests += 1
+		n := requests
+		mut.Unlock()
+		if n <= limited {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		mock.ServeHTTP(w, r)
+	}))
+}
+
+func TestHTTPClientRateLimit(t *testing.T) {
+	end := time.Now().Unix() / 3600 * 3600
+	server := newLimitedServer(2)
+	defer server.Close()
+	candles, err := NewHTTPClient(server.URL, 0, 2).Candles("BTC", "1h", end-3*3600, end-1, 10)
+	if err != nil || len(candles) != 3 {
+		t.Errorf("Expected [3] candles after [2] retries Received [%d] %v", len(candles), err)
+	}
+	server = newLimitedServer(2)
+	defer server.Close()
+	_, err = NewHTTPClient(server.URL, 0, 1).Candles("BTC", "1h", end-3*3600, end-1, 10)
+	rateLimitErr := &RateLimitError{}
+	if !errors.As(err, &rateLimitErr) || rateLimitErr.Retries != 1 {
+		t.Errorf("Expected a RateLimitError after [1] retry Received %v", err)
+	}
+}
+
+func TestHTTPClientBadRequest(t *testing.T) {
+	server := httptest.NewServer(NewMockServer(0).Handler())
+	defer server.Close()
+	if _, err := NewHTTPClient(server.URL, 0, 0).Candles("BTC", "2h", 0, 3600, 10); err == nil {
+		t.Error("Expected an error for an invalid interval")
+	}
+}
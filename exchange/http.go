@@ -0,0 +1,85 @@
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+Client for the candle API (see exchange.go).
+Requests are spaced at least minInterval apart, 429 responses are retried after Retry-After
+(or an exponential backoff starting at one second) up to maxRetries times.
+*/
+type HTTPClient struct {
+	baseURL     string
+	http        *http.Client
+	minInterval time.Duration
+	maxRetries  int
+	mut         sync.Mutex
+	last        time.Time
+}
+
+func NewHTTPClient(baseURL string, minInterval time.Duration, maxRetries int) *HTTPClient {
+	return &HTTPClient{
+		baseURL:     baseURL,
+		http:        &http.Client{Timeout: 30 * time.Second},
+		minInterval: minInterval,
+		maxRetries:  maxRetries,
+	}
+}
+
+func (c *HTTPClient) Candles(symbol string, interval string, start int64, end int64, limit int) ([]Candle, error) {
+	query := url.Values{}
+	query.Set("symbol", symbol)
+	query.Set("interval", interval)
+	query.Set("start", strconv.FormatInt(start, 10))
+	query.Set("end", strconv.FormatInt(end, 10))
+	query.Set("limit", strconv.Itoa(limit))
+	reqURL := c.baseURL + CandlesPath + "?" + query.Encode()
+	backoff := time.Second
+	for retry := 0; ; retry++ {
+		c.wait()
+		resp, err := c.http.Get(reqURL)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			resp.Body.Close()
+			if retry >= c.maxRetries {
+				return nil, &RateLimitError{retry}
+			}
+			delay := backoff
+			if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+				delay = time.Duration(secs) * time.Second
+			}
+			time.Sleep(delay)
+			backoff *= 2
+			continue
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			return nil, fmt.Errorf("%s: %s %s", reqURL, resp.Status, body)
+		}
+		candles := []Candle{}
+		if err := json.NewDecoder(resp.Body).Decode(&candles); err != nil {
+			return nil, err
+		}
+		return candles, nil
+	}
+}
+
+func (c *HTTPClient) wait() {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if next := c.last.Add(c.minInterval); time.Now().Before(next) {
+		time.Sleep(time.Until(next))
+	}
+	c.last = time.Now()
+}
@@ -0,0 +1,119 @@
+package exchange
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"Simulations_v5/data"
+)
+
+/*
+Local stand-in for an exchange's candle API (see exchange.go), for offline tests.
+
+Candles are a deterministic function of symbol and time (the same candle is returned by every request),
+no candle is newer than the current time. More than requestsPerSecond requests in a second are answered
+with 429 and Retry-After: 1.
+*/
+
+const mockMaxLimit = 1000
+
+type MockServer struct {
+	requestsPerSecond int
+	mut               sync.Mutex
+	second            int64
+	requests          int
+}
+
+func NewMockServer(requestsPerSecond int) *MockServer {
+	return &MockServer{requestsPerSecond: requestsPerSecond}
+}
+
+func (m *MockServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(CandlesPath, m.handleCandles)
+	return mux
+}
+
+func (m *MockServer) handleCandles(w http.ResponseWriter, r *http.Request) {
+	if !m.allow() {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+	query := r.URL.Query()
+	symbol := query.Get("symbol")
+	interval, err := data.ParseInterval(query.Get("interval"))
+	if symbol == "" || err != nil {
+		http.Error(w, "symbol and interval (1m, 5m, 1h, 4h or 1d) are required", http.StatusBadRequest)
+		return
+	}
+	start, err1 := strconv.ParseInt(query.Get("start"), 10, 64)
+	end, err2 := strconv.ParseInt(query.Get("end"), 10, 64)
+	if err1 != nil || err2 != nil {
+		http.Error(w, "start and end must be Unix seconds", http.StatusBadRequest)
+		return
+	}
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit < 1 || limit > mockMaxLimit {
+		limit = mockMaxLimit
+	}
+	if now := time.Now().Unix(); end > now {
+		end = now
+	}
+	seed := symbolSeed(symbol)
+	candles := []Candle{}
+	first := start + (interval-start%interval)%interval
+	for t := first; t <= end-interval+1 && len(candles) < limit; t += interval {
+		candles = append(candles, mockCandle(seed, t, interval))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(candles)
+}
+
+func (m *MockServer) allow() bool {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	now := time.Now().Unix()
+	if now != m.second {
+		m.second = now
+		m.requests = 0
+	}
+	m.requests += 1
+	return m.requestsPerSecond <= 0 || m.requests <= m.requestsPerSecond
+}
+
+func symbolSeed(symbol string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(symbol))
+	return int64(h.Sum64() >> 1)
+}
+
+/*
+Price of symbol at time t: slow and fast cycles around a base price with per-candle noise
+*/
+func mockPrice(seed int64, t int64) float64 {
+	base := 10.0 + float64(seed%1000)
+	cycles := 1 + 0.3*math.Sin(float64(t)/(86400*90)+float64(seed%7)) + 0.1*math.Sin(float64(t)/(86400*7))
+	noise := rand.New(rand.NewSource(seed ^ t)).NormFloat64()
+	return base * cycles * (1 + 0.005*noise)
+}
+
+func mockCandle(seed int64, t int64, interval int64) Candle {
+	open := mockPrice(seed, t)
+	close := mockPrice(seed, t+interval)
+	rng := rand.New(rand.NewSource(seed ^ (t * 31)))
+	return Candle{
+		Time:   t,
+		Open:   open,
+		High:   math.Max(open, close) * (1 + 0.002*math.Abs(rng.NormFloat64())),
+		Low:    math.Min(open, close) * (1 - 0.002*math.Abs(rng.NormFloat64())),
+		Close:  close,
+		Volume: 100 * (1 + math.Abs(rng.NormFloat64())),
+	}
+}
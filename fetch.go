@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"gopkg.in/ini.v1"
+
+	"Simulations_v5/data"
+	"Simulations_v5/exchange"
+)
+
+/*
+Downloads OHLCV candles of every asset into <data_dir>/<asset>/<asset>_<interval>.csv.
+An existing file is updated from its last candle, indicators are recomputed over the whole file.
+*/
+
+type fetchParams struct {
+	baseURL     string        // Base URL of the candle API
+	interval    string        // Interval of the downloaded candles
+	start       time.Time     // First candle downloaded for a new file
+	limit       int           // Candles per request
+	minInterval time.Duration // Minimum time between requests
+	maxRetries  int           // Retries of a rate limited request
+}
+
+func runFetch(confFile string, args []string) error {
+	flags := flag.NewFlagSet("fetch", flag.ContinueOnError)
+	mock := flags.Bool("mock", false, "serve candles from a local mock server instead of [Fetch] base_url")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	params, err := getFetchParams(confFile)
+	if err != nil {
+		return err
+	}
+	if *mock {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return err
+		}
+		server := &http.Server{Handler: exchange.NewMockServer(0).Handler()}
+		go server.Serve(listener)
+		defer server.Close()
+		params.baseURL = "http://" + listener.Addr().String()
+	}
+	intervalSeconds, err := data.ParseInterval(params.interval)
+	if err != nil {
+		return err
+	}
+	client := exchange.NewHTTPClient(params.baseURL, params.minInterval, params.maxRetries)
+	color.Green("Fetching %s candles from %s", params.interval, params.baseURL)
+	start := time.Now()
+	for _, asset := range assets {
+		n, err := fetchAsset(client, asset, params, intervalSeconds)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("[%s] %d new candles\n", asset, n)
+	}
+	color.Cyan("Done in %v\nData can be found in %s", time.Since(start), dataDir)
+	return nil
+}
+
+func fetchAsset(client exchange.Client, asset string, params fetchParams, intervalSeconds int64) (int, error) {
+	assetDir := fmt.Sprintf("%s/%s", dataDir, asset)
+	if _, err := os.Stat(assetDir); os.IsNotExist(err) {
+		err := os.MkdirAll(assetDir, 0755)
+		if err != nil {
+			return 0, err
+		}
+	}
+	fileName := fmt.Sprintf("%s/%s_%s.csv", assetDir, asset, params.interval)
+	bars := []data.Bar{}
+	emas := append([]int{}, EMAValues...)
+	from := params.start.Unix()
+	if _, err := os.Stat(fileName); err == nil {
+		existing, err := data.CSVProvider{}.Load(asset, fileName, data.Window{})
+		if err != nil {
+			return 0, err
+		}
+		bars = existing.Bars
+		for _, name := range existing.Columns {
+			if n, err := strconv.Atoi(strings.TrimPrefix(name, "EMA_")); err == nil && strings.HasPrefix(name, "EMA_") {
+				emas = append(emas, n)
+			}
+		}
+		if len(bars) > 0 {
+			from = bars[len(bars)-1].Date + intervalSeconds
+		}
+	}
+	candles, err := exchange.FetchAll(client, asset, params.interval, intervalSeconds, from, time.Now().Unix(), params.limit)
+	if err != nil {
+		return 0, fmt.Errorf("[%s] %w", asset, err)
+	}
+	if len(candles) == 0 {
+		return 0, nil
+	}
+	for _, c := range candles {
+		bars = append(bars, data.Bar{Date: c.Time, Open: c.Open, High: c.High, Low: c.Low, Close: c.Close, Volume: c.Volume})
+	}
+	dataFrame := data.BarsToDataFrame(bars, distinctInts(emas), true, true)
+	// Write to a temporary file first so an interrupted fetch never leaves a partial data file
+	tmpFile := fileName + ".tmp"
+	f, err := os.Create(tmpFile)
+	if err != nil {
+		return 0, err
+	}
+	if err := dataFrame.WriteCSV(f); err != nil {
+		f.Close()
+		return 0, err
+	}
+	if err := f.Close(); err != nil {
+		return 0, err
+	}
+	return len(candles), os.Rename(tmpFile, fileName)
+}
+
+func distinctInts(values []int) []int {
+	seen := map[int]bool{}
+	out := []int{}
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+/*
+Serves the mock candle API until interrupted
+*/
+func runMockServer(args []string) error {
+	flags := flag.NewFlagSet("mockserver", flag.ContinueOnError)
+	addr := flags.String("addr", "127.0.0.1:8090", "address to listen on")
+	rate := flags.Int("rate", 10, "requests per second before answering 429 (0 for no limit)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	color.Green("Serving mock candles on http://%s%s", *addr, exchange.CandlesPath)
+	return http.ListenAndServe(*addr, exchange.NewMockServer(*rate).Handler())
+}
+
+/*
+[Fetch] base_url, interval (default [Data] interval), start_date (default [Simulation] start_date),
+limit (default 1000), min_request_interval in milliseconds (default 200) and max_retries (default 5)
+*/
+func getFetchParams(confFile string) (fetchParams, error) {
+	cfg, err := ini.Load(confFile)
+	if err != nil {
+		fmt.Printf("Failed to read %v\n", err)
+		return fetchParams{}, err
+	}
+	section := cfg.Section("Fetch")
+	params := fetchParams{
+		baseURL:     strings.TrimSuffix(section.Key("base_url").String(), "/"),
+		interval:    section.Key("interval").MustString(cfg.Section("Data").Key("interval").MustString("1h")),
+		limit:       section.Key("limit").MustInt(1000),
+		minInterval: time.Duration(section.Key("min_request_interval").MustInt(200)) * time.Millisecond,
+		maxRetries:  section.Key("max_retries").MustInt(5),
+	}
+	params.start, err = time.ParseInLocation("02Jan2006", section.Key("start_date").MustString(startDate), location)
+	if err != nil {
+		return fetchParams{}, errors.New("Config file not configured for [start_date]")
+	}
+	if params.limit < 1 {
+		return fetchParams{}, errors.New("Config file not configured for [limit]")
+	}
+	return params, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"Simulations_v5/data"
+	"Simulations_v5/exchange"
+)
+
+func TestFetchAsset(t *testing.T) {
+	server := httptest.NewServer(exchange.NewMockServer(0).Handler())
+	defer server.Close()
+	dataDir = t.TempDir()
+	EMAValues = []int{50}
+	client := exchange.NewHTTPClient(server.URL, 0, 0)
+	params := fetchParams{baseURL: server.URL, interval: "1h", start: time.Now().Add(-10 * 24 * time.Hour), limit: 100}
+	n, err := fetchAsset(client, "BTC", params, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n < 239 || n > 240 {
+		t.Fatalf("Expected [240] candles Received [%d]", n)
+	}
+	// An existing file is updated from its last candle
+	more, err := fetchAsset(client, "BTC", params, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := data.CSVProvider{}.Load("BTC", dataDir+"/BTC/BTC_1h.csv", data.Window{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Bars) != n+more {
+		t.Fatalf("Expected [%d] bars Received [%d]", n+more, len(set.Bars))
+	}
+	for i := 1; i < len(set.Bars); i++ {
+		if set.Bars[i].Date != set.Bars[i-1].Date+3600 {
+			t.Fatalf("Bar %d: expected date [%d] Received [%d]", i, set.Bars[i-1].Date+3600, set.Bars[i].Date)
+		}
+	}
+	if _, ok := set.Extra["EMA_50"]; !ok {
+		t.Errorf("Expected an EMA_50 column Received %v", set.Columns)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/go-gota/gota/dataframe"
+	"gopkg.in/ini.v1"
+)
+
+/*
+Genetic algorithm over the values configured in [Parameters].
+Each gene is an index into one of the parameter lists:
+
+	0 Strategies
+	1 EMAValues
+	2 ReinvestPercentageValues
+	3 MinReturnValues
+	4 PercentDrop
+	5 BalanceTripwires
+*/
+
+type gaParams struct {
+	population     int     // Number of individuals per generation
+	generations    int     // Number of generations to evolve
+	crossoverRate  float64 // Probability two parents are crossed over instead of cloned
+	mutationRate   float64 // Probability each gene is replaced with a random value
+	elitism        int     // Number of best individuals carried into the next generation unchanged
+	tournamentSize int     // Number of individuals competing for each parent slot
+	seed           int64   // Seed for the random number generator
+}
+
+type individual struct {
+	genes   []int
+	fitness float64
+}
+
+func runGA(ctx context.Context, confFile string) error {
+	params, err := getGAParams(confFile)
+	if err != nil {
+		return err
+	}
+	err = validateAssets()
+	if err != nil {
+		return err
+	}
+	color.Green("Running Genetic Algorithm (seed %d)", params.seed)
+	start := time.Now()
+	runName := newRunName("GA_", start)
+	manifest, err := newManifest("ga", "GA_", confFile, runName, start, len(assets), map[string]int64{"ga": params.seed})
+	if err != nil {
+		return err
+	}
+	// One job per asset
+	completed, failed := 0, 0
+	defer func() {
+		if err := manifest.finish(completed, failed, len(assets)-completed-failed); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}()
+	for _, asset := range assets {
+		data, dataFile, hash, err := getData(asset, dataDir)
+		if err != nil {
+			fmt.Println(err)
+			failed += 1
+			continue
+		}
+		best, err := evolve(ctx, asset, data, dataFile, hash, params, runName)
+		if err != nil {
+			return err
+		}
+		p := genesToParams(best.genes)
+		color.Cyan("[%s] Best: %g %s EMA-%d Reinvest %g MinReturn %g PercentDrop %g BalanceTrip %g", asset, best.fitness, p.Strategy, p.EMA, p.ReinvestPerc, p.MinReturn, p.PercentDrop, p.BalanceTrip)
+		completed += 1
+	}
+	s := fmt.Sprintf("Done in %v\nGA results can be found in %s", time.Since(start), outputDir)
+	color.Cyan(s)
+	return nil
+}
+
+func evolve(ctx context.Context, asset string, data dataframe.DataFrame, dataFile string, hash string, params gaParams, runName string) (individual, error) {
+	outDir := fmt.Sprintf("%s/%s", outputDir, asset)
+	if _, err := os.Stat(outDir); os.IsNotExist(err) {
+		err := os.MkdirAll(outDir, 0755)
+		if err != nil {
+			return individual{}, err
+		}
+	}
+	fileName := fmt.Sprintf("%s/GA_%s.csv", outDir, runName)
+	f, err := os.Create(fileName)
+	if err != nil {
+		return individual{}, err
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintf(f, "Seed %d\nGeneration,Best,Mean,Strategy,EMA,Reinvest,MinReturn,PercentDrop,BalanceTrip\n", params.seed); err != nil {
+		return individual{}, err
+	}
+	rng := rand.New(rand.NewSource(params.seed))
+	sizes := geneSizes()
+	cache := map[ParamSet]float64{}
+	pop := make([]individual, params.population)
+	for i := range pop {
+		pop[i] = randomIndividual(rng, sizes)
+	}
+	for gen := 0; gen < params.generations; gen++ {
+		evaluate(ctx, asset, data, dataFile, hash, pop, cache)
+		// Fitness of cancelled simulations is meaningless, completed generations are already in the report
+		if err := ctx.Err(); err != nil {
+			return individual{}, fmt.Errorf("[%s] Interrupted in generation %d, completed generations can be found in %s: %w", asset, gen, fileName, err)
+		}
+		sort.SliceStable(pop, func(i, j int) bool {
+			return pop[i].fitness > pop[j].fitness
+		})
+		mean := 0.0
+		for _, ind := range pop {
+			mean += ind.fitness
+		}
+		mean /= float64(len(pop))
+		p := genesToParams(pop[0].genes)
+		line := fmt.Sprintf("%d,%g,%g,%s,%d,%g,%g,%g,%g\n", gen, pop[0].fitness, mean, p.Strategy, p.EMA, p.ReinvestPerc, p.MinReturn, p.PercentDrop, p.BalanceTrip)
+		if _, err := f.Write([]byte(line)); err != nil {
+			return individual{}, err
+		}
+		if gen == params.generations-1 {
+			break
+		}
+		next := make([]individual, 0, params.population)
+		for i := 0; i < params.elitism && i < len(pop); i++ {
+			next = append(next, individual{append([]int{}, pop[i].genes...), pop[i].fitness})
+		}
+		for len(next) < params.population {
+			a := tournament(rng, pop, params.tournamentSize)
+			b := tournament(rng, pop, params.tournamentSize)
+			child := append([]int{}, a.genes...)
+			if rng.Float64() < params.crossoverRate {
+				for g := range child {
+					if rng.Intn(2) == 1 {
+						child[g] = b.genes[g]
+					}
+				}
+			}
+			for g := range child {
+				if rng.Float64() < params.mutationRate {
+					child[g] = rng.Intn(sizes[g])
+				}
+			}
+			next = append(next, individual{genes: child})
+		}
+		pop = next
+	}
+	return pop[0], nil
+}
+
+/*
+Runs every individual without a cached fitness concurrently.
+Fitness is the final equity of the simulation (revenue included, see finalEquity) so results do not depend on scheduling.
+*/
+func evaluate(ctx context.Context, asset string, data dataframe.DataFrame, dataFile string, hash string, pop []individual, cache map[ParamSet]float64) {
+	var wg sync.WaitGroup
+	var mut sync.Mutex
+	pending := map[ParamSet]bool{}
+	for _, ind := range pop {
+		p := genesToParams(ind.genes)
+		if _, ok := cache[p]; ok || pending[p] {
+			continue
+		}
+		pending[p] = true
+		wg.Add(1)
+		go func(p ParamSet) {
+			defer wg.Done()
+			r := runParamSet(ctx, asset, data, dataFile, hash, p)
+			fitness := finalEquity(r)
+			if r.Err != nil {
+				if !errors.Is(r.Err, context.Canceled) {
+					fmt.Println(r.Err)
+				}
+				fitness = 0.0
+			}
+			mut.Lock()
+			cache[p] = fitness
+			mut.Unlock()
+		}(p)
+	}
+	wg.Wait()
+	for i := range pop {
+		pop[i].fitness = cache[genesToParams(pop[i].genes)]
+	}
+}
+
+func tournament(rng *rand.Rand, pop []individual, size int) individual {
+	best := pop[rng.Intn(len(pop))]
+	for i := 1; i < size; i++ {
+		challenger := pop[rng.Intn(len(pop))]
+		if challenger.fitness > best.fitness {
+			best = challenger
+		}
+	}
+	return best
+}
+
+func randomIndividual(rng *rand.Rand, sizes []int) individual {
+	genes := make([]int, len(sizes))
+	for g, size := range sizes {
+		genes[g] = rng.Intn(size)
+	}
+	return individual{genes: genes}
+}
+
+func geneSizes() []int {
+	return []int{len(Strategies), len(EMAValues), len(ReinvestPercentageValues), len(MinReturnValues), len(PercentDrop), len(BalanceTripwires)}
+}
+
+func genesToParams(genes []int) ParamSet {
+	return ParamSet{
+		Strategy:     Strategies[genes[0]],
+		EMA:          EMAValues[genes[1]],
+		ReinvestPerc: ReinvestPercentageValues[genes[2]],
+		MinReturn:    MinReturnValues[genes[3]],
+		PercentDrop:  PercentDrop[genes[4]],
+		BalanceTrip:  BalanceTripwires[genes[5]],
+	}
+}
+
+func getGAParams(confFile string) (gaParams, error) {
+	cfg, err := ini.Load(confFile)
+	if err != nil {
+		fmt.Printf("Failed to read %v\n", err)
+		return gaParams{}, err
+	}
+	section := cfg.Section("GA")
+	params := gaParams{
+		population:     section.Key("population").MustInt(50),
+		generations:    section.Key("generations").MustInt(20),
+		crossoverRate:  section.Key("crossover_rate").MustFloat64(0.8),
+		mutationRate:   section.Key("mutation_rate").MustFloat64(0.1),
+		elitism:        section.Key("elitism").MustInt(2),
+		tournamentSize: section.Key("tournament_size").MustInt(3),
+		seed:           section.Key("seed").MustInt64(time.Now().UnixNano()),
+	}
+	if params.population < 2 {
+		return gaParams{}, errors.New("Config file not configured for [population] (must be at least 2)")
+	}
+	if params.generations < 1 {
+		return gaParams{}, errors.New("Config file not configured for [generations]")
+	}
+	if params.elitism < 0 || params.elitism >= params.population {
+		return gaParams{}, errors.New("Config file not configured for [elitism] (must be less than population)")
+	}
+	if params.tournamentSize < 1 {
+		return gaParams{}, errors.New("Config file not configured for [tournament_size]")
+	}
+	return params, nil
+}
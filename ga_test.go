@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestEvaluateFitnessIsFinalEquity(t *testing.T) {
+	writeTestConfig(t, "")
+	data, dataFile, hash, err := getData("SYNTH", dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	sizes := geneSizes()
+	pop := []individual{randomIndividual(rng, sizes), randomIndividual(rng, sizes)}
+	evaluate(context.Background(), "SYNTH", data, dataFile, hash, pop, map[ParamSet]float64{})
+	revenue := false
+	for _, ind := range pop {
+		r := runParamSet(context.Background(), "SYNTH", data, dataFile, hash, genesToParams(ind.genes))
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		if ind.fitness != finalEquity(r) {
+			t.Errorf("%v: expected fitness [%g] Received [%g]", genesToParams(ind.genes), finalEquity(r), ind.fitness)
+		}
+		revenue = revenue || finalEquity(r) != r.FinalValue
+	}
+	if !revenue {
+		t.Error("Expected an individual with revenue, fitness would equal FinalValue either way")
+	}
+}
+
+func TestEvolveCancelled(t *testing.T) {
+	writeTestConfig(t, "")
+	data, dataFile, hash, err := getData("SYNTH", dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	params := gaParams{population: 4, generations: 3, crossoverRate: 0.8, mutationRate: 0.1, elitism: 1, tournamentSize: 2, seed: 1}
+	if _, err := evolve(ctx, "SYNTH", data, dataFile, hash, params, "TEST"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the GA to be cancelled Received %v", err)
+	}
+}
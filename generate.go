@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"gopkg.in/ini.v1"
+
+	"Simulations_v5/calendar"
+	"Simulations_v5/data"
+	"Simulations_v5/synth"
+)
+
+/*
+Writes a synthetic price series (see synth) to <data_dir>/<asset>/<asset>_<seed>.csv in the format getData reads.
+Bars cover the open bars of [Simulation] start_date to end_date at the [Data] interval, with every [Parameters] EMA.
+*/
+func runGenerate(confFile string, args []string) error {
+	flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	model := flags.String("model", "", "gbm, ou, regime or jump (default [Synth] model)")
+	seed := flags.Int64("seed", 0, "seed of the random number generator (default [Synth] seed)")
+	asset := flags.String("asset", "", "asset directory to write to (default SYNTH_<model>)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	params, confModel, confSeed, err := getSynthParams(confFile)
+	if err != nil {
+		return err
+	}
+	if *model == "" {
+		*model = confModel
+	}
+	// Any seed can be chosen, 0 included, a -seed not given takes the config's
+	seedSet := false
+	flags.Visit(func(f *flag.Flag) { seedSet = seedSet || f.Name == "seed" })
+	if !seedSet {
+		*seed = confSeed
+	}
+	if *asset == "" {
+		*asset = "SYNTH_" + *model
+	}
+	m, err := synth.NewModel(*model, params)
+	if err != nil {
+		return err
+	}
+	start, err := time.ParseInLocation("02Jan2006", startDate, location)
+	if err != nil {
+		return err
+	}
+	end, err := time.ParseInLocation("02Jan2006", endDate, location)
+	if err != nil {
+		return err
+	}
+	if end.Unix()-start.Unix() < barInterval {
+		return errors.New("[end_date] must be after [start_date]")
+	}
+	// Only bars the [calendar] is open for, a closed market does not move
+	dates := []int64{}
+	for t := start.Unix(); t < end.Unix(); t += barInterval {
+		if tradingCalendar.IsOpen(t, barInterval) {
+			dates = append(dates, t)
+		}
+	}
+	if len(dates) == 0 {
+		return fmt.Errorf("No [%ds] bar between [start_date] and [end_date] is open in the [calendar]", barInterval)
+	}
+	bars := synth.Generate(m, params.StartPrice, *seed, dates, calendar.BarsPerYear(tradingCalendar, barInterval))
+	dataFrame := data.BarsToDataFrame(bars, distinctInts(EMAValues), true, true)
+	assetDir := fmt.Sprintf("%s/%s", dataDir, *asset)
+	if _, err := os.Stat(assetDir); os.IsNotExist(err) {
+		err := os.MkdirAll(assetDir, 0755)
+		if err != nil {
+			return err
+		}
+	}
+	fileName := fmt.Sprintf("%s/%s_%d.csv", assetDir, *asset, *seed)
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	if err := dataFrame.WriteCSV(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	color.Cyan("Generated %d %s bars (seed %d) in %s", len(bars), *model, *seed, fileName)
+	return nil
+}
+
+/*
+[Synth] model (default gbm), seed (default time), start_price (default 100), drift (0.1), volatility (0.5),
+mean_reversion (5), mean (start_price), bear_drift (-0.3), bear_volatility (0.8), switch_rate (4),
+jump_intensity (10), jump_mean (-0.02) and jump_std (0.05)
+*/
+func getSynthParams(confFile string) (synth.Params, string, int64, error) {
+	cfg, err := ini.Load(confFile)
+	if err != nil {
+		fmt.Printf("Failed to read %v\n", err)
+		return synth.Params{}, "", 0, err
+	}
+	section := cfg.Section("Synth")
+	params := synth.Params{
+		StartPrice:     section.Key("start_price").MustFloat64(100),
+		Drift:          section.Key("drift").MustFloat64(0.1),
+		Volatility:     section.Key("volatility").MustFloat64(0.5),
+		MeanReversion:  section.Key("mean_reversion").MustFloat64(5),
+		BearDrift:      section.Key("bear_drift").MustFloat64(-0.3),
+		BearVolatility: section.Key("bear_volatility").MustFloat64(0.8),
+		SwitchRate:     section.Key("switch_rate").MustFloat64(4),
+		JumpIntensity:  section.Key("jump_intensity").MustFloat64(10),
+		JumpMean:       section.Key("jump_mean").MustFloat64(-0.02),
+		JumpStd:        section.Key("jump_std").MustFloat64(0.05),
+	}
+	params.Mean = section.Key("mean").MustFloat64(params.StartPrice)
+	if params.StartPrice <= 0.0 {
+		return synth.Params{}, "", 0, errors.New("Config file not configured for [start_price]")
+	}
+	if params.Volatility < 0.0 || params.BearVolatility < 0.0 || params.JumpStd < 0.0 {
+		return synth.Params{}, "", 0, errors.New("Config file not configured for [volatility]")
+	}
+	model := section.Key("model").MustString("gbm")
+	seed := section.Key("seed").MustInt64(time.Now().UnixNano())
+	return params, model, seed, nil
+}
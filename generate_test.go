@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"Simulations_v5/data"
+)
+
+func TestGenerateSeedZero(t *testing.T) {
+	confFile := writeTestConfig(t, "")
+	if err := runGenerate(confFile, []string{"-asset", "SYNTH", "-seed", "0"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(fmt.Sprintf("%s/SYNTH/SYNTH_0.csv", dataDir)); err != nil {
+		t.Errorf("Expected the series of seed 0: %v", err)
+	}
+}
+
+func TestGenerateEquityCalendar(t *testing.T) {
+	writeTestConfig(t, "[Simulation]\ncalendar = equity\ntimezone = America/New_York\n\n[Data]\ninterval = 1d\n")
+	set, err := data.CSVProvider{}.Load("SYNTH", fmt.Sprintf("%s/SYNTH/SYNTH_1.csv", dataDir), data.Window{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 42 weekdays in January and February 2023 less New Year's Day (observed), MLK Day and Presidents' Day
+	if len(set.Bars) != 39 {
+		t.Errorf("Expected a bar per trading day [39] Received [%d]", len(set.Bars))
+	}
+	for _, bar := range set.Bars {
+		if !tradingCalendar.IsOpen(bar.Date, barInterval) {
+			t.Errorf("Bar at [%d] is outside the calendar", bar.Date)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"Simulations_v5/report"
+	"Simulations_v5/results"
+)
+
+/*
+Parameter sensitivity heatmaps for a completed results file.
+For every pair of swept parameters a cell holds the best metric over all other parameters,
+its stability score compares the cell against its neighbours:
+
+	stability = 1 - (cell - mean(neighbours)) / (max - min)
+
+1 means the cell sits on a plateau, lower values mean it is a spike.
+For metrics where lower is better (see results.Better) the best cell is the lowest and the difference is reversed.
+*/
+
+/*
+Best cells less stable than this are reported as spikes: the cell beats the mean of its neighbours
+by more than a quarter of the whole range of the metric
+*/
+const spikeStability = 0.75
+
+type sweptParam struct {
+	name  string
+	value func(r results.Record) string
+}
+
+var sweptParams = []sweptParam{
+	{"strategy", func(r results.Record) string { return r.Strategy }},
+	{"ema", func(r results.Record) string { return strconv.Itoa(r.EMA) }},
+	{"reinvest", func(r results.Record) string { return strconv.FormatFloat(r.ReinvestPerc, 'g', -1, 64) }},
+	{"minReturn", func(r results.Record) string { return strconv.FormatFloat(r.MinReturn, 'g', -1, 64) }},
+	{"percentDrop", func(r results.Record) string { return strconv.FormatFloat(r.PercentDrop, 'g', -1, 64) }},
+	{"balanceTrip", func(r results.Record) string { return strconv.FormatFloat(r.BalanceTrip, 'g', -1, 64) }},
+}
+
+func runHeatmap(args []string) error {
+	flags := flag.NewFlagSet("heatmap", flag.ContinueOnError)
+	metric := flags.String("metric", "profit", fmt.Sprintf("metric to plot (%s)", strings.Join(results.Metrics, " ")))
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("Usage: heatmap [-metric name] <results.csv>")
+	}
+	resultsFile := flags.Arg(0)
+	records, err := results.Load(resultsFile)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("No results in %s", resultsFile)
+	}
+	investment := getResultsInvestment(resultsFile)
+	values := make([]float64, len(records))
+	for i, r := range records {
+		values[i], err = r.Metric(*metric, investment)
+		if err != nil {
+			return err
+		}
+	}
+	swept := []sweptParam{}
+	for _, p := range sweptParams {
+		if len(distinctValues(records, p)) > 1 {
+			swept = append(swept, p)
+		}
+	}
+	if len(swept) < 2 {
+		return fmt.Errorf("%s sweeps fewer than 2 parameters", resultsFile)
+	}
+	name := strings.TrimSuffix(filepath.Base(resultsFile), filepath.Ext(resultsFile))
+	outDir := fmt.Sprintf("%s/%s/heatmaps_%s_%s", outputDir, records[0].Asset, name, *metric)
+	if _, err := os.Stat(outDir); os.IsNotExist(err) {
+		err := os.MkdirAll(outDir, 0755)
+		if err != nil {
+			return err
+		}
+	}
+	body := fmt.Sprintf("<p>%s (%d results), metric %s</p>\n", resultsFile, len(records), *metric)
+	summary := [][]string{}
+	for i := 0; i < len(swept); i++ {
+		for j := i + 1; j < len(swept); j++ {
+			metricMap, stabilityMap, stability := getHeatmaps(records, values, swept[i], swept[j], *metric)
+			for _, h := range []report.Heatmap{metricMap, stabilityMap} {
+				svg := h.SVG()
+				fileName := fmt.Sprintf("%s/%s.svg", outDir, strings.ReplaceAll(h.Title, " ", "_"))
+				if err := os.WriteFile(fileName, []byte(svg), 0644); err != nil {
+					return err
+				}
+				body += svg
+			}
+			label := "plateau"
+			if stability < spikeStability {
+				label = "spike"
+			}
+			summary = append(summary, []string{swept[i].name, swept[j].name, strconv.FormatFloat(stability, 'f', 3, 64), label})
+		}
+	}
+	body = "<h2>Stability of the best cell</h2>\n" + report.Table([]string{"X", "Y", "Stability", ""}, summary) + body
+	page := report.Page(fmt.Sprintf("%s %s sensitivity", records[0].Asset, *metric), body)
+	if err := os.WriteFile(outDir+"/index.html", []byte(page), 0644); err != nil {
+		return err
+	}
+	for _, row := range summary {
+		if row[3] == "spike" {
+			color.Yellow("%s x %s: best cell stability %s (spike)", row[0], row[1], row[2])
+		} else {
+			color.Green("%s x %s: best cell stability %s (plateau)", row[0], row[1], row[2])
+		}
+	}
+	color.Cyan("Heatmaps can be found in %s", outDir)
+	return nil
+}
+
+/*
+Returns the metric heatmap, the stability heatmap and the stability of the best cell
+*/
+func getHeatmaps(records []results.Record, values []float64, px sweptParam, py sweptParam, metric string) (report.Heatmap, report.Heatmap, float64) {
+	xs := distinctValues(records, px)
+	ys := distinctValues(records, py)
+	xIdx := map[string]int{}
+	for i, x := range xs {
+		xIdx[x] = i
+	}
+	yIdx := map[string]int{}
+	for i, y := range ys {
+		yIdx[y] = i
+	}
+	grid := make([][]float64, len(ys))
+	for y := range grid {
+		grid[y] = make([]float64, len(xs))
+		for x := range grid[y] {
+			grid[y][x] = math.NaN()
+		}
+	}
+	for i, r := range records {
+		x := xIdx[px.value(r)]
+		y := yIdx[py.value(r)]
+		if math.IsNaN(grid[y][x]) || results.Better(metric, values[i], grid[y][x]) {
+			grid[y][x] = values[i]
+		}
+	}
+	min := math.Inf(1)
+	max := math.Inf(-1)
+	bestX, bestY := -1, -1
+	for y := range grid {
+		for x, v := range grid[y] {
+			if math.IsNaN(v) {
+				continue
+			}
+			min = math.Min(min, v)
+			max = math.Max(max, v)
+			if bestX < 0 || results.Better(metric, v, grid[bestY][bestX]) {
+				bestX, bestY = x, y
+			}
+		}
+	}
+	stability := make([][]float64, len(ys))
+	for y := range grid {
+		stability[y] = make([]float64, len(xs))
+		for x, v := range grid[y] {
+			stability[y][x] = math.NaN()
+			if math.IsNaN(v) {
+				continue
+			}
+			sum := 0.0
+			n := 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					ny, nx := y+dy, x+dx
+					if (dx == 0 && dy == 0) || ny < 0 || nx < 0 || ny >= len(ys) || nx >= len(xs) || math.IsNaN(grid[ny][nx]) {
+						continue
+					}
+					sum += grid[ny][nx]
+					n += 1
+				}
+			}
+			if n == 0 || max == min {
+				stability[y][x] = 1.0
+				continue
+			}
+			excess := v - sum/float64(n)
+			if results.LowerIsBetter(metric) {
+				excess = -excess
+			}
+			stability[y][x] = 1 - excess/(max-min)
+		}
+	}
+	metricMap := report.Heatmap{
+		Title:      fmt.Sprintf("%s %s x %s", metric, px.name, py.name),
+		XLabel:     px.name,
+		YLabel:     py.name,
+		Xs:         xs,
+		Ys:         ys,
+		Values:     grid,
+		HighlightX: bestX,
+		HighlightY: bestY,
+	}
+	stabilityMap := metricMap
+	stabilityMap.Title = fmt.Sprintf("stability %s x %s", px.name, py.name)
+	stabilityMap.Values = stability
+	best := 1.0
+	if bestX >= 0 {
+		best = stability[bestY][bestX]
+	}
+	return metricMap, stabilityMap, best
+}
+
+/*
+Distinct values of a parameter, sorted numerically when possible
+*/
+func distinctValues(records []results.Record, p sweptParam) []string {
+	seen := map[string]bool{}
+	values := []string{}
+	for _, r := range records {
+		v := p.value(r)
+		if !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		a, errA := strconv.ParseFloat(values[i], 64)
+		b, errB := strconv.ParseFloat(values[j], 64)
+		if errA == nil && errB == nil {
+			return a < b
+		}
+		return values[i] < values[j]
+	})
+	return values
+}
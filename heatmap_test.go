@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Simulations_v5/results"
+)
+
+/*
+Records over a 3x3 grid of min returns and percent drops with the metric values[y][x]
+*/
+func newTestGrid(values [][]float64) ([]results.Record, []float64) {
+	records := []results.Record{}
+	flat := []float64{}
+	for y, row := range values {
+		for x, v := range row {
+			records = append(records, results.Record{Strategy: "MACD", MinReturn: float64(x + 1), PercentDrop: float64(y + 1)})
+			flat = append(flat, v)
+		}
+	}
+	return records, flat
+}
+
+func TestHeatmapStability(t *testing.T) {
+	minReturn, percentDrop := sweptParams[3], sweptParams[4]
+	for _, test := range []struct {
+		name   string
+		metric string
+		values [][]float64
+		best   [2]int
+		spike  bool
+	}{
+		{"spike", "profit", [][]float64{{0, 0, 0}, {0, 100, 0}, {0, 0, 0}}, [2]int{1, 1}, true},
+		{"plateau", "profit", [][]float64{{0, 0, 0}, {0, 98, 99}, {0, 99, 100}}, [2]int{2, 2}, false},
+		{"flat", "profit", [][]float64{{5, 5, 5}, {5, 5, 5}, {5, 5, 5}}, [2]int{0, 0}, false},
+		{"fee spike", "fees", [][]float64{{50, 50, 50}, {50, 50, 50}, {50, 50, 0}}, [2]int{2, 2}, true},
+		{"fee plateau", "fees", [][]float64{{0, 2, 50}, {2, 0, 50}, {50, 50, 50}}, [2]int{0, 0}, false},
+	} {
+		records, values := newTestGrid(test.values)
+		metricMap, _, stability := getHeatmaps(records, values, minReturn, percentDrop, test.metric)
+		if best := [2]int{metricMap.HighlightX, metricMap.HighlightY}; best != test.best {
+			t.Errorf("%s: expected best cell %v Received %v", test.name, test.best, best)
+		}
+		if spike := stability < spikeStability; spike != test.spike {
+			t.Errorf("%s: expected spike [%t] Received stability [%g]", test.name, test.spike, stability)
+		}
+	}
+}
+
+func TestResultsInvestment(t *testing.T) {
+	writeTestConfig(t, "")
+	dir := t.TempDir()
+	outputDir = dir
+	m := &runManifest{Run: "RUN", Sweep: manifestSweep{InvestAmt: 5000}, fileName: getManifestFile("", "RUN")}
+	if err := m.write(); err != nil {
+		t.Fatal(err)
+	}
+	if investment := getResultsInvestment(filepath.Join(dir, "SYNTH", "RUN.csv")); investment != 5000 {
+		t.Errorf("Expected the investment of the run [5000] Received [%g]", investment)
+	}
+	if err := os.Remove(getManifestFile("", "RUN")); err != nil {
+		t.Fatal(err)
+	}
+	if investment := getResultsInvestment(filepath.Join(dir, "SYNTH", "RUN.csv")); investment != investmentAMT {
+		t.Errorf("Expected the configured investment [%g] without a manifest Received [%g]", investmentAMT, investment)
+	}
+}
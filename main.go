@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"math"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/cheggaaa/pb"
@@ -17,7 +21,11 @@ import (
 
 	"gopkg.in/ini.v1"
 
+	"Simulations_v5/calendar"
+	"Simulations_v5/data"
+	"Simulations_v5/results"
 	"Simulations_v5/simulation"
+	"Simulations_v5/strategy"
 )
 
 type InvalidDataError struct {
@@ -44,105 +52,398 @@ var startDate string
 var endDate string
 var SimsComplete = 0
 var numSims = 0
+var numFailed = 0
+var numCancelled = 0
 var WG sync.WaitGroup
 var outFileMut sync.Mutex
 var dataFileMut sync.Mutex
 var bar pb.ProgressBar
+var logDir string
+var outputDir string
+var dataDir string
+var assets []string
+var htmlReports bool
+var eventFormat string
+var eventLog bool
+var resultStore results.Store
+var qualityConf data.QualityConfig
+var barInterval int64
+var dataProvider data.DataProvider
+var location *time.Location
+var tradingCalendar calendar.Calendar
+var expectedBars = -1
+
+type ParamSet struct {
+	Strategy     string
+	EMA          int
+	ReinvestPerc float64
+	MinReturn    float64
+	PercentDrop  float64
+	BalanceTrip  float64
+}
+
+/*
+Same order as the parameters in the results file: strat,ema,reinvest,minReturn,percentDrop,balanceTrip
+*/
+func (p ParamSet) String() string {
+	return fmt.Sprintf("%s,%d,%g,%g,%g,%g", p.Strategy, p.EMA, p.ReinvestPerc, p.MinReturn, p.PercentDrop, p.BalanceTrip)
+}
 
 func main() {
-	color.Green("Running Simulations")
 	confFile := "/mnt/glados/Programming/Go/Simulations_v5/conf/conf.ini"
-	err := getParameters(confFile)
+	command := "sweep"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+	err := loadConfig(confFile)
 	if err != nil {
 		log.Fatal(err)
 	}
-	logDir, err := getLogDir(confFile)
+	// The first SIGINT/SIGTERM cancels ctx and lets in-flight simulations finish, a second one exits immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	args := []string{}
+	if len(os.Args) > 2 {
+		args = os.Args[2:]
+	}
+	switch command {
+	case "sweep":
+		err = runSweep(ctx, confFile, args)
+	case "ga":
+		err = runGA(ctx, confFile)
+	case "walkforward":
+		err = runWalkForward(ctx, confFile)
+	case "montecarlo":
+		err = runMonteCarlo(ctx, confFile)
+	case "heatmap":
+		err = runHeatmap(os.Args[2:])
+	case "summarize":
+		err = runSummarize(os.Args[2:])
+	case "fetch":
+		err = runFetch(confFile, os.Args[2:])
+	case "mockserver":
+		err = runMockServer(os.Args[2:])
+	case "generate":
+		err = runGenerate(confFile, os.Args[2:])
+	case "replay":
+		err = runReplay(ctx, os.Args[2:])
+	case "coordinator":
+		err = runCoordinator(ctx, confFile, args)
+	case "worker":
+		err = runWorker(ctx, confFile, args)
+	case "serve":
+		err = runServe(ctx, confFile, args)
+	default:
+		err = fmt.Errorf("Unknown command [%s]", command)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
-	outputDir, err := getOutputDir(confFile)
+}
+
+/*
+Loads directories, assets and parameters shared by every command
+*/
+func loadConfig(confFile string) error {
+	err := getParameters(confFile)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	dataDir, err := getDataDir(confFile)
+	logDir, err = getLogDir(confFile)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	startDate, endDate, err := getDates(confFile)
+	outputDir, err = getOutputDir(confFile)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	dataDir, err = getDataDir(confFile)
+	if err != nil {
+		return err
 	}
 	investmentAMT, taxRate, fees, err = getSimulationParams(confFile)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	assets, err = getAssets(confFile)
+	if err != nil {
+		return err
+	}
+	htmlReports, err = getHTMLReports(confFile)
+	if err != nil {
+		return err
+	}
+	eventFormat, err = getEventFormat(confFile)
+	if err != nil {
+		return err
+	}
+	eventLog, err = getEventLog(confFile)
+	if err != nil {
+		return err
+	}
+	qualityConf, err = getQualityConfig(confFile)
+	if err != nil {
+		return err
+	}
+	barInterval, err = getBarInterval(confFile)
+	if err != nil {
+		return err
+	}
+	dataSources, err = getDataSources(confFile)
+	if err != nil {
+		return err
+	}
+	dataProvider, err = getDataProvider(confFile)
+	if err != nil {
+		return err
+	}
+	location, tradingCalendar, err = getCalendar(confFile)
+	if err != nil {
+		return err
+	}
+	// Dates or calendar may differ from a previously loaded config
+	expectedBars = -1
+	return nil
+}
+
+/*
+Runs every asset and [Parameters] combination, -resume <run> continues an interrupted run (see checkpoint.go).
+Once ctx is cancelled no new simulation starts, running ones stop and a partial summary is printed.
+*/
+func runSweep(ctx context.Context, confFile string, args []string) error {
+	flags := flag.NewFlagSet("sweep", flag.ContinueOnError)
+	resume := flags.String("resume", "", "name of an interrupted run to continue")
+	if err := flags.Parse(args); err != nil {
+		return err
 	}
+	_, err := sweep(ctx, confFile, *resume, true, nil)
+	return err
+}
+
+/*
+Runs the sweep configured in confFile and returns its run name, results are also saved to extra (if not nil).
+The progress bar is only drawn if showProgress is set.
+*/
+func sweep(ctx context.Context, confFile string, resume string, showProgress bool, extra results.Store) (string, error) {
 	start := time.Now()
-	outFileName := fmt.Sprintf("%d%s%d_%d%d", start.Day(), start.Month(), start.Year(), start.Hour(), start.Minute())
-	assets, err := getAssets(confFile)
+	outFileName, manifest, err := startRun(confFile, resume, start)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
-	numSims = getNumSims(assets)
+	if extra != nil {
+		resultStore = results.NewMultiStore(resultStore, extra)
+	}
+	defer resultStore.Close()
 	WG.Add(numSims)
 	bar = *pb.New(numSims)
-	bar.Start()
+	if showProgress {
+		bar.Start()
+	}
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			color.Yellow("\nInterrupted, stopping running simulations (again to force quit)")
+		case <-finished:
+		}
+	}()
 	for _, asset := range assets {
-		for _, strat := range Strategies {
-			for _, ema := range EMAValues {
-				for _, reinvestPerc := range ReinvestPercentageValues {
-					for _, minReturn := range MinReturnValues {
-						for _, percentDrop := range PercentDrop {
-							for _, balanceTrip := range BalanceTripwires {
-								logFile := fmt.Sprintf("%s/%s-%s/%s/%s/EMA-%v/", logDir, startDate, endDate, asset, strat, ema)
-								if _, err := os.Stat(logFile); os.IsNotExist(err) {
-									err := os.MkdirAll(logFile, 0755)
-									if err != nil {
-										log.Fatal(err)
-									}
-								}
-								logFile = fmt.Sprintf("%s/MPBR-%v_%v_%v_%v.log", logFile, minReturn, percentDrop, balanceTrip, reinvestPerc)
-								// fmt.Printf("%s/MPBR-%v_%v_%v_%v.log", logFile, minReturn, percentDrop, balanceTrip, reinvestPerc)
-								go simulate(asset, strat, ema, reinvestPerc, minReturn, percentDrop, balanceTrip, logFile, dataDir, outputDir, outFileName)
-							}
-						}
-					}
-				}
+		for _, p := range getParamGrid() {
+			if runCheckpoint.isDone(jobKey(asset, p)) {
+				continue
 			}
+			logFile, err := getLogFile(asset, p)
+			if err != nil {
+				logResult(simulation.Result{AssetName: asset, Err: err}, p)
+				WG.Done()
+				continue
+			}
+			go simulate(ctx, asset, p.Strategy, p.EMA, p.ReinvestPerc, p.MinReturn, p.PercentDrop, p.BalanceTrip, logFile, dataDir)
 		}
 	}
 	WG.Wait()
-	bar.Finish()
-	s := fmt.Sprintf("Done in %v\nRaw results can be found in %s", time.Since(start), outputDir)
+	close(finished)
+	if showProgress {
+		bar.Finish()
+	}
+	return outFileName, finishRun(ctx, "sweep", outFileName, manifest, start)
+}
+
+/*
+Validates the assets and opens the checkpoint, manifest and results store of a sweep (resume continues an
+interrupted run). Sets numSims to the number of simulations left, the caller closes resultStore.
+*/
+func startRun(confFile string, resume string, start time.Time) (string, *runManifest, error) {
+	var err error
+	overfitConf, err = getOverfitParams(confFile)
+	if err != nil {
+		return "", nil, err
+	}
+	// Counters of a previous run in the same process (see serve.go)
+	numFailed, numCancelled, SimsComplete = 0, 0, 0
+	trialsMut.Lock()
+	trials = map[string][]trial{}
+	trialsMut.Unlock()
+	err = validateAssets()
+	if err != nil {
+		return "", nil, err
+	}
+	color.Green("Running Simulations")
+	outFileName := newRunName("", start)
+	keys := getJobKeys()
+	var manifest *runManifest
+	if resume != "" {
+		outFileName = resume
+		runCheckpoint, err = resumeCheckpoint(outFileName, keys)
+		if err == nil {
+			manifest, err = resumeManifest(confFile, outFileName, start, len(keys))
+		}
+	} else {
+		runCheckpoint, err = newCheckpoint(outFileName, keys)
+		if err == nil {
+			manifest, err = newManifest("sweep", "", confFile, outFileName, start, len(keys), nil)
+		}
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	run := results.Run{
+		Name:          outFileName,
+		Started:       start,
+		StartDate:     startDate,
+		EndDate:       endDate,
+		InvestAmt:     investmentAMT,
+		TaxRate:       taxRate,
+		Fees:          fees,
+		SellCondition: sellCondition,
+	}
+	resultStore, err = getResultStore(confFile, run)
+	if err != nil {
+		return "", nil, err
+	}
+	outFileMut.Lock()
+	numSims = getNumSims(assets) - len(runCheckpoint.done)
+	outFileMut.Unlock()
+	if resume != "" {
+		color.Green("Resuming %s: %d of %d simulations left", outFileName, numSims, len(keys))
+	}
+	return outFileName, manifest, nil
+}
+
+/*
+Writes the overfit reports and the final manifest of a sweep and prints its summary,
+command is the command that continues the run when it was interrupted
+*/
+func finishRun(ctx context.Context, command string, outFileName string, manifest *runManifest, start time.Time) error {
+	writeOverfitReports(outFileName)
+	// Jobs completed before a resume are in the checkpoint too
+	completed := len(runCheckpoint.done)
+	if err := manifest.finish(completed, numFailed, manifest.Jobs-completed-numFailed); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if numFailed > 0 {
+		color.Red("%d of %d simulations failed, failures were recorded with the results", numFailed, numSims)
+	}
+	if ctx.Err() != nil {
+		color.Yellow("Interrupted after %v: %d completed, %d failed, %d not run", time.Since(start), numSims-numFailed-numCancelled, numFailed, numCancelled)
+		color.Yellow("Partial results can be found in %s, continue with: %s -resume %s", outputDir, command, outFileName)
+		return fmt.Errorf("Run [%s] interrupted: %w", outFileName, ctx.Err())
+	}
+	s := fmt.Sprintf("Done in %v\nRaw results can be found in %s\nManifest: %s", time.Since(start), outputDir, manifest.fileName)
 	color.Cyan(s)
+	return nil
+}
+
+/*
+Event log (or HTML report) file of a sweep simulation, its directory is created if either is enabled
+*/
+func getLogFile(asset string, p ParamSet) (string, error) {
+	logFile := getLogFileName(logDir, startDate, endDate, asset, p, getLogExtension())
+	if _, err := os.Stat(filepath.Dir(logFile)); os.IsNotExist(err) && (eventLog || htmlReports) {
+		err := os.MkdirAll(filepath.Dir(logFile), 0755)
+		if err != nil {
+			return "", err
+		}
+	}
+	return logFile, nil
+}
+
+func getLogFileName(logDir string, startDate string, endDate string, asset string, p ParamSet, ext string) string {
+	logFile := fmt.Sprintf("%s/%s-%s/%s/%s/EMA-%v/", logDir, startDate, endDate, asset, p.Strategy, p.EMA)
+	// fmt.Printf("%s/MPBR-%v_%v_%v_%v.log", logFile, minReturn, percentDrop, balanceTrip, reinvestPerc)
+	return fmt.Sprintf("%s/MPBR-%v_%v_%v_%v.%s", logFile, p.MinReturn, p.PercentDrop, p.BalanceTrip, p.ReinvestPerc, ext)
+}
+
+func getRunName(start time.Time) string {
+	return fmt.Sprintf("%d%s%d_%02d%02d%02d", start.Day(), start.Month(), start.Year(), start.Hour(), start.Minute(), start.Second())
+}
+
+func simulate(ctx context.Context, asset string, strat string, ema int, reinvestPerc float64, minReturn float64, percentDrop float64, balanceTrip float64, logFile string, dataDir string) {
+	defer WG.Done()
+	p := ParamSet{strat, ema, reinvestPerc, minReturn, percentDrop, balanceTrip}
+	if err := ctx.Err(); err != nil {
+		logResult(simulation.Result{AssetName: asset, Err: err}, p)
+		return
+	}
+	r := runJob(ctx, asset, p, logFile, dataDir)
+	logResult(r, p)
+	recordTrial(r, p)
 }
 
-func simulate(asset string, strat string, ema int, reinvestPerc float64, minReturn float64, percentDrop float64, balanceTrip float64, logFile string, dataDir string, outputDir string, outFileName string) {
-	data, dataFile, err := getData(asset, dataDir)
+/*
+Loads data and runs a single simulation, every failure is returned in Result.Err
+*/
+func runJob(ctx context.Context, asset string, p ParamSet, logFile string, dataDir string) simulation.Result {
+	data, dataFile, hash, err := getData(asset, dataDir)
 	if err != nil {
-		fmt.Println(err)
-		var invalidDataError *InvalidDataError
-		if errors.As(err, &invalidDataError) {
-			bar.Increment()
-			WG.Done()
-			// log.Println(err)
-			return
-		} else {
-			log.Fatal(err)
+		return simulation.Result{AssetName: asset, Err: err}
+	}
+	sim, err := newParamSetSimulation(asset, data, dataFile, hash, p, logFile)
+	if err != nil {
+		return simulation.Result{AssetName: asset, Err: err}
+	}
+	simulation.SetEventSink(&sim, getEventSink(logFile))
+	r := simulation.RunSimulation(ctx, &sim)
+	if htmlReports && r.ResultString != "" {
+		err = simulation.WriteReport(&sim, strings.TrimSuffix(logFile, filepath.Ext(logFile))+".html")
+		if err != nil && r.Err == nil {
+			r.Err = err
 		}
 	}
+	return r
+}
+
+/*
+Runs a single parameter set against already loaded data without an event log
+*/
+func runParamSet(ctx context.Context, asset string, data dataframe.DataFrame, dataFile string, hash string, p ParamSet) simulation.Result {
+	sim, err := newParamSetSimulation(asset, data, dataFile, hash, p, "")
+	if err != nil {
+		return simulation.Result{AssetName: asset, Err: err}
+	}
+	simulation.SetEventSink(&sim, simulation.NopSink{})
+	return simulation.RunSimulation(ctx, &sim)
+}
+
+/*
+Simulation of p with the loaded [Simulation] settings and timezone, hash is the sha256 data was loaded from
+*/
+func newParamSetSimulation(asset string, data dataframe.DataFrame, dataFile string, hash string, p ParamSet, logFile string) (simulation.Simulation, error) {
 	sim, err := simulation.NewSimulation(asset, investmentAMT, taxRate, fees, dataFile, data, logFile)
 	if err != nil {
-		fmt.Println("hit new sim")
-		log.Fatal(err)
+		return simulation.Simulation{}, err
 	}
-	err = simulation.SetStratParams(&sim, strat, sellCondition, ema, reinvestPerc, minReturn, percentDrop, balanceTrip)
+	err = simulation.SetStratParams(&sim, p.Strategy, sellCondition, p.EMA, p.ReinvestPerc, p.MinReturn, p.PercentDrop, p.BalanceTrip)
 	if err != nil {
-		fmt.Println("hit set strat")
-		log.Fatal(err)
+		return simulation.Simulation{}, err
 	}
-	r := simulation.RunSimulation(&sim)
-	logResult(r, outputDir, outFileName)
-	WG.Done()
+	simulation.SetDataHash(&sim, hash)
+	simulation.SetLocation(&sim, location)
+	return sim, nil
 }
 
 func getDates(confFile string) (string, string, error) {
@@ -172,6 +473,66 @@ func getLogDir(confFile string) (string, error) {
 	return logDir, nil
 }
 
+func getHTMLReports(confFile string) (bool, error) {
+	cfg, err := ini.Load(confFile)
+	if err != nil {
+		fmt.Printf("Failed to read %v\n", err)
+		return false, err
+	}
+	return cfg.Section("Files").Key("html_reports").MustBool(false), nil
+}
+
+/*
+Format of the per simulation event logs: json (JSON Lines, default) or text (original MPBR lines)
+*/
+func getEventFormat(confFile string) (string, error) {
+	cfg, err := ini.Load(confFile)
+	if err != nil {
+		fmt.Printf("Failed to read %v\n", err)
+		return "", err
+	}
+	format := cfg.Section("Files").Key("event_format").MustString("json")
+	if !simulation.IsValidEventFormat(format) {
+		return "", fmt.Errorf("Config file not configured for [event_format] (json or text, received %s)", format)
+	}
+	return format, nil
+}
+
+/*
+Whether per simulation event logs are written: file (default) or none
+*/
+func getEventLog(confFile string) (bool, error) {
+	cfg, err := ini.Load(confFile)
+	if err != nil {
+		fmt.Printf("Failed to read %v\n", err)
+		return false, err
+	}
+	eventLog := cfg.Section("Files").Key("event_log").MustString("file")
+	switch eventLog {
+	case "file":
+		return true, nil
+	case "none":
+		return false, nil
+	}
+	return false, fmt.Errorf("Config file not configured for [event_log] (file or none, received %s)", eventLog)
+}
+
+func getEventSink(logFile string) simulation.EventSink {
+	if !eventLog {
+		return simulation.NopSink{}
+	}
+	// eventFormat is validated by getEventFormat
+	formatter, _ := simulation.GetEventFormatter(eventFormat)
+	return simulation.NewFileSink(logFile, formatter)
+}
+
+func getLogExtension() string {
+	if eventFormat == "json" {
+		return "jsonl"
+	}
+	return "log"
+}
+
 func getDataDir(confFile string) (string, error) {
 	cfg, err := ini.Load(confFile)
 	if err != nil {
@@ -208,87 +569,353 @@ func getSimulationParams(confFile string) (float64, float64, float64, error) {
 	return investmentAMT, taxRate, fees, nil
 }
 
-func getMostRecentFile(dir string) (string, error) {
-	files, err := ioutil.ReadDir(dir)
-	if err != nil {
-		fmt.Printf("Failed to read %v\n", err)
-		return "", err
-	}
-	var newestFile string
-	var newestTime int64 = 0
-	for _, f := range files {
-		fi, err := os.Stat(dir + f.Name())
-		if err != nil {
-			fmt.Println(err)
-		}
-		currTime := fi.ModTime().Unix()
-		if currTime > newestTime {
-			newestTime = currTime
-			newestFile = f.Name()
-		}
-	}
-	return fmt.Sprintf("%s/%s", dir, newestFile), nil
+/*
+Data of asset, the data file and the sha256 of the bytes the data was read from
+*/
+func getData(asset string, dataDir string) (dataframe.DataFrame, string, string, error) {
+	dataFrame, dataFile, hash, _, err := loadData(asset, dataDir)
+	return dataFrame, dataFile, hash, err
 }
 
-func getData(asset string, dataDir string) (dataframe.DataFrame, string, error) {
+/*
+Reads the data file of asset (see [DataSources]) and applies the [Data] quality policy
+*/
+func loadData(asset string, dataDir string) (dataframe.DataFrame, string, string, data.QualityReport, error) {
 	dataFileMut.Lock()
 	defer dataFileMut.Unlock()
-	assetDir := fmt.Sprintf("%s/%s/", dataDir, asset)
-	dataFile, err := getMostRecentFile(assetDir)
-	file, err := os.Open(dataFile)
+	dataFile, err := getDataFile(asset, dataDir)
+	if err != nil {
+		return dataframe.DataFrame{}, "", "", data.QualityReport{}, err
+	}
+	dataFrame, hash, report, err := readData(asset, dataFile)
+	return dataFrame, dataFile, hash, report, err
+}
+
+/*
+Reads dataFile as the data of asset and returns the sha256 of the bytes it was read from,
+must be called with dataFileMut held
+*/
+func readData(asset string, dataFile string) (dataframe.DataFrame, string, data.QualityReport, error) {
+	start, err := time.ParseInLocation("02Jan2006", startDate, location)
+	if err != nil {
+		return dataframe.DataFrame{}, "", data.QualityReport{}, err
+	}
+	end, err := time.ParseInLocation("02Jan2006", endDate, location)
+	if err != nil {
+		return dataframe.DataFrame{}, "", data.QualityReport{}, err
+	}
+	// Only the bars from start_date up to (not including) end_date are loaded
+	bars, err := dataProvider.Load(asset, dataFile, data.Window{Start: start.Unix(), End: end.Unix() - 1})
+	if err != nil {
+		return dataframe.DataFrame{}, "", data.QualityReport{}, err
+	}
+	if len(bars.Bars) == 0 {
+		return dataframe.DataFrame{}, "", data.QualityReport{}, fmt.Errorf("[%s] %s has no bar between [start_date] and [end_date]", asset, dataFile)
+	}
+	dataFrame := bars.DataFrame()
+	if dataFrame.Err != nil {
+		return dataframe.DataFrame{}, "", data.QualityReport{}, dataFrame.Err
+	}
+	err = simulation.CheckColumns(asset, dataFrame.Names(), "", 0, 0)
 	if err != nil {
-		return dataframe.DataFrame{}, "", err
+		return dataframe.DataFrame{}, "", data.QualityReport{}, err
 	}
-	dataFrame := dataframe.ReadCSV(file)
-	file.Close()
 	// dataFrame = dataFrame.Drop(2)
 	// dataFrame = dataFrame.Drop(2)
 	// dataFrame = dataFrame.Drop(2)
 	// dataFrame = dataFrame.Drop(3)
-	dataFrame = dataFrame.Arrange(dataframe.Sort("Date"))
-	start, err := time.Parse("02Jan2006", startDate)
+	sourceInterval, err := data.DetectInterval(dataFrame)
 	if err != nil {
-		return dataframe.DataFrame{}, "", err
+		return dataframe.DataFrame{}, "", data.QualityReport{}, err
+	}
+	isOpen := getIsOpen(sourceInterval)
+	if isOpen != nil {
+		dataFrame, err = data.FilterSessions(dataFrame, isOpen)
+		if err != nil {
+			return dataframe.DataFrame{}, "", data.QualityReport{}, err
+		}
 	}
-	end, err := time.Parse("02Jan2006", endDate)
+	if sourceInterval > barInterval {
+		return dataframe.DataFrame{}, "", data.QualityReport{}, fmt.Errorf("[%s] Data interval [%ds] is coarser than [interval] [%ds]", asset, sourceInterval, barInterval)
+	}
+	conf := qualityConf
+	conf.IntervalSeconds = sourceInterval
+	conf.IsOpen = isOpen
+	dataFrame, report, err := data.CheckQuality(asset, dataFile, dataFrame, conf)
 	if err != nil {
-		return dataframe.DataFrame{}, "", err
+		return dataframe.DataFrame{}, "", report, err
+	}
+	if sourceInterval > 0 && sourceInterval < barInterval {
+		dataFrame, err = data.Resample(dataFrame, barInterval, location)
+		if err != nil {
+			return dataframe.DataFrame{}, "", report, err
+		}
 	}
-	delta := int((end.Unix() - start.Unix()) / 3600)
-	if math.Abs(float64(dataFrame.Nrow()-delta)/24.0) > 600 {
+	// Counting open bars is slow for fine intervals, the dates never change during a run
+	if expectedBars < 0 {
+		expectedBars = calendar.CountBars(tradingCalendar, start.Unix(), end.Unix(), barInterval)
+	}
+	delta := expectedBars
+	if delta == 0 {
+		return dataframe.DataFrame{}, "", report, fmt.Errorf("[%s] No [%ds] bar between [start_date] and [end_date] is open in the [calendar]", asset, barInterval)
+	}
+	barsPerDay := float64(delta) / (float64(end.Unix()-start.Unix()) / (24 * 60 * 60))
+	if math.Abs(float64(dataFrame.Nrow()-delta)/barsPerDay) > 600 {
 		// fmt.Println("INVALID DATA")
-		return dataframe.DataFrame{}, "", &InvalidDataError{asset, dataFrame.Nrow(), delta}
+		return dataframe.DataFrame{}, "", report, &InvalidDataError{asset, dataFrame.Nrow(), delta}
 	}
-	return dataFrame, dataFile, nil
+	return dataFrame, bars.Hash, report, nil
 }
 
-func logResult(r simulation.Result, outputDir string, outFileName string) {
-	outFileMut.Lock()
-	defer outFileMut.Unlock()
-	outDir := fmt.Sprintf("%s/%s", outputDir, r.AssetName)
+/*
+[Data] quality_policy (fail, warn (default), ffill or drop) and spike_threshold (default 0.5).
+Gaps are measured against the interval of the data file.
+*/
+func getQualityConfig(confFile string) (data.QualityConfig, error) {
+	cfg, err := ini.Load(confFile)
+	if err != nil {
+		fmt.Printf("Failed to read %v\n", err)
+		return data.QualityConfig{}, err
+	}
+	section := cfg.Section("Data")
+	conf := data.QualityConfig{
+		Policy:         section.Key("quality_policy").MustString("warn"),
+		SpikeThreshold: section.Key("spike_threshold").MustFloat64(0.5),
+	}
+	if !data.IsValidPolicy(conf.Policy) {
+		return data.QualityConfig{}, fmt.Errorf("Config file not configured for [quality_policy] (fail, warn, ffill or drop, received %s)", conf.Policy)
+	}
+	if conf.SpikeThreshold <= 0.0 {
+		return data.QualityConfig{}, errors.New("Config file not configured for [spike_threshold]")
+	}
+	return conf, nil
+}
+
+/*
+[Simulation] timezone (IANA name, default UTC) used for start_date/end_date, resampling and human readable
+timestamps, and calendar: crypto (default, 24/7) or equity (weekdays from session_start (default 09:30) to
+session_end (default 16:00), closed on NYSE holidays and the comma separated holidays, e.g. 26Dec2022)
+*/
+func getCalendar(confFile string) (*time.Location, calendar.Calendar, error) {
+	cfg, err := ini.Load(confFile)
+	if err != nil {
+		fmt.Printf("Failed to read %v\n", err)
+		return nil, nil, err
+	}
+	section := cfg.Section("Simulation")
+	loc, err := time.LoadLocation(section.Key("timezone").MustString("UTC"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Config file not configured for [timezone]: %w", err)
+	}
+	holidays := []string{}
+	for _, h := range section.Key("holidays").Strings(",") {
+		if h != "" {
+			holidays = append(holidays, h)
+		}
+	}
+	cal, err := calendar.New(section.Key("calendar").MustString("crypto"), loc, section.Key("session_start").MustString("09:30"), section.Key("session_end").MustString("16:00"), holidays)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Config file not configured for [calendar]: %w", err)
+	}
+	return loc, cal, nil
+}
+
+/*
+Whether a bar of intervalSeconds is expected at a timestamp, nil if the market never closes
+*/
+func getIsOpen(intervalSeconds int64) func(t int64) bool {
+	cal := tradingCalendar
+	if _, ok := cal.(calendar.Crypto); ok {
+		return nil
+	}
+	return func(t int64) bool {
+		return cal.IsOpen(t, intervalSeconds)
+	}
+}
+
+/*
+Reader of the data files: csv (default), csv.gz, parquet, jsonl or auto (by file extension)
+*/
+func getDataProvider(confFile string) (data.DataProvider, error) {
+	cfg, err := ini.Load(confFile)
+	if err != nil {
+		fmt.Printf("Failed to read %v\n", err)
+		return nil, err
+	}
+	provider, err := data.GetProvider(cfg.Section("Files").Key("data_provider").MustString("csv"))
+	if err != nil {
+		return nil, fmt.Errorf("Config file not configured for [data_provider]: %w", err)
+	}
+	return provider, nil
+}
+
+/*
+[Data] interval of the simulated bars: 1m, 5m, 1h (default), 4h or 1d.
+Finer data files are resampled to this interval.
+*/
+func getBarInterval(confFile string) (int64, error) {
+	cfg, err := ini.Load(confFile)
+	if err != nil {
+		fmt.Printf("Failed to read %v\n", err)
+		return 0, err
+	}
+	interval := cfg.Section("Data").Key("interval").MustString("1h")
+	seconds, err := data.ParseInterval(interval)
+	if err != nil {
+		return 0, fmt.Errorf("Config file not configured for [interval]: %w", err)
+	}
+	return seconds, nil
+}
+
+/*
+Writes the data quality report of asset to <output_dir>/<asset>/<data file>_quality.txt
+*/
+func writeQualityReport(report data.QualityReport) error {
+	outDir := fmt.Sprintf("%s/%s", outputDir, report.Asset)
 	if _, err := os.Stat(outDir); os.IsNotExist(err) {
 		err := os.MkdirAll(outDir, 0755)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 	}
-	fileName := fmt.Sprintf("%s/%s.csv", outDir, outFileName)
-	f, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		log.Fatal(err)
+	name := strings.TrimSuffix(filepath.Base(report.DataFile), filepath.Ext(report.DataFile))
+	fileName := fmt.Sprintf("%s/%s_quality.txt", outDir, name)
+	return os.WriteFile(fileName, []byte(report.String()), 0644)
+}
+
+/*
+Rejects strategies, sell conditions and asset/strategy/EMA combinations whose data is missing columns
+before any simulation runs. Assets with an InvalidDataError are left to fail per simulation.
+Writes a data quality report for every asset.
+*/
+func validateAssets() error {
+	errs := []error{}
+	for _, strat := range Strategies {
+		if !strategy.IsValidStrategy(strat) {
+			errs = append(errs, fmt.Errorf("Invalid strategy [%s]", strat))
+		}
 	}
-	if _, err := f.Write([]byte(r.ResultString)); err != nil {
-		log.Fatal(err)
+	if !strategy.IsValidSellCondition(sellCondition) {
+		errs = append(errs, fmt.Errorf("Invalid sell condition [%d]", sellCondition))
 	}
-	if err := f.Close(); err != nil {
-		log.Fatal(err)
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	for _, asset := range assets {
+		dataFrame, _, _, report, err := loadData(asset, dataDir)
+		if report.DataFile != "" {
+			if report.HasIssues() {
+				color.Yellow("[%s] Data quality (%s): %s", asset, report.Policy, report.Summary())
+			}
+			if err := writeQualityReport(report); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err != nil {
+			var invalidDataError *InvalidDataError
+			if !errors.As(err, &invalidDataError) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		for _, strat := range Strategies {
+			for _, ema := range EMAValues {
+				err := simulation.CheckColumns(asset, dataFrame.Names(), strat, sellCondition, ema)
+				if err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+/*
+Saves the result (if the simulation completed) and records the failure (if any), saved results are checkpointed.
+Write errors are printed and counted, they never stop the sweep.
+*/
+func logResult(r simulation.Result, p ParamSet) {
+	outFileMut.Lock()
+	defer outFileMut.Unlock()
+	defer bar.Increment()
+	SimsComplete += 1
+	// Cancelled simulations are neither saved nor checkpointed so a resumed run starts them again
+	if errors.Is(r.Err, context.Canceled) {
+		numCancelled += 1
+		return
+	}
+	if r.ResultString != "" {
+		if err := resultStore.Save(r); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			r.Err = errors.Join(r.Err, err)
+		}
+	}
+	if r.Err != nil {
+		numFailed += 1
+		if err := resultStore.SaveFailure(r.AssetName, p.String(), r.Err); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return
+	}
+	if err := runCheckpoint.markDone(jobKey(r.AssetName, p)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+/*
+Opens the results store configured in [Files] results_store (csv or sqlite)
+*/
+func getResultStore(confFile string, run results.Run) (results.Store, error) {
+	cfg, err := ini.Load(confFile)
+	if err != nil {
+		fmt.Printf("Failed to read %v\n", err)
+		return nil, err
+	}
+	storeType := cfg.Section("Files").Key("results_store").MustString("csv")
+	switch storeType {
+	case "csv":
+		return results.NewCSVStore(outputDir, run), nil
+	case "sqlite":
+		dbFile := cfg.Section("Files").Key("results_db").MustString(fmt.Sprintf("%s/results.db", outputDir))
+		if _, err := os.Stat(outputDir); os.IsNotExist(err) {
+			err := os.MkdirAll(outputDir, 0755)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return results.NewSQLiteStore(dbFile, run)
 	}
-	f.Close()
-	bar.Increment()
+	return nil, fmt.Errorf("Config file not configured for [results_store] (csv or sqlite, received %s)", storeType)
 }
 
 func getNumSims(assets []string) int {
-	return len(assets) * len(EMAValues) * len(ReinvestPercentageValues) * len(MinReturnValues) * len(PercentDrop) * len(BalanceTripwires) * len(Strategies)
+	return len(assets) * len(getParamGrid())
+}
+
+/*
+Every distinct combination of the values configured in [Parameters], a value listed twice is run once
+*/
+func getParamGrid() []ParamSet {
+	grid := []ParamSet{}
+	seen := map[ParamSet]bool{}
+	for _, strat := range Strategies {
+		for _, ema := range EMAValues {
+			for _, reinvestPerc := range ReinvestPercentageValues {
+				for _, minReturn := range MinReturnValues {
+					for _, percentDrop := range PercentDrop {
+						for _, balanceTrip := range BalanceTripwires {
+							p := ParamSet{strat, ema, reinvestPerc, minReturn, percentDrop, balanceTrip}
+							if !seen[p] {
+								seen[p] = true
+								grid = append(grid, p)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return grid
 }
 
 func getAssets(confFile string) ([]string, error) {
@@ -298,7 +925,14 @@ func getAssets(confFile string) ([]string, error) {
 		return nil, err
 	}
 	assetsStr := cfg.Section("Simulation").Key("assets").String()
-	assets := strings.Split(assetsStr, " ")
+	assets := []string{}
+	seen := map[string]bool{}
+	for _, asset := range strings.Split(assetsStr, " ") {
+		if !seen[asset] {
+			seen[asset] = true
+			assets = append(assets, asset)
+		}
+	}
 	return assets, nil
 }
 
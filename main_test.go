@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"Simulations_v5/results"
+	"Simulations_v5/simulation"
+)
+
+func TestEquityDailySweep(t *testing.T) {
+	confFile := writeTestConfig(t, "[Simulation]\ncalendar = equity\ntimezone = America/New_York\n\n[Data]\ninterval = 1d\n")
+	if _, err := sweep(context.Background(), confFile, "", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if numFailed != 0 || SimsComplete != getNumSims(assets) {
+		t.Fatalf("Expected [%d] completed simulations Received [%d] (%d failed)", getNumSims(assets), SimsComplete, numFailed)
+	}
+}
+
+func TestSweepUnwritableLogDir(t *testing.T) {
+	confFile := writeTestConfig(t, "[Files]\nevent_log = file\n")
+	// A file where the log directory should be
+	if err := os.WriteFile(logDir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sweep(context.Background(), confFile, "", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if numFailed != getNumSims(assets) {
+		t.Fatalf("Expected [%d] failed simulations Received [%d]", getNumSims(assets), numFailed)
+	}
+}
+
+func TestSweepDuplicateValues(t *testing.T) {
+	confFile := writeTestConfig(t, "[Simulation]\nassets = SYNTH SYNTH\n\n[Parameters]\nema_values = 50 50\n")
+	if getNumSims(assets) != 4 {
+		t.Fatalf("Expected [4] simulations Received [%d]", getNumSims(assets))
+	}
+	done := make(chan error)
+	go func() {
+		_, err := sweep(context.Background(), confFile, "", false, nil)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Minute):
+		t.Fatal("Sweep did not finish")
+	}
+	if numFailed != 0 || SimsComplete != 4 {
+		t.Fatalf("Expected [4] completed simulations Received [%d] (%d failed)", SimsComplete, numFailed)
+	}
+}
+
+func TestResumeSweep(t *testing.T) {
+	confFile := writeTestConfig(t, "[Files]\nevent_log = file\nresults_store = sqlite\n")
+	runName, err := sweep(context.Background(), confFile, "", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Interrupted before the last job was checkpointed
+	doneFile := fmt.Sprintf("%s/%s.done", outputDir, runName)
+	done, err := readLines(doneFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p ParamSet
+	for _, q := range getParamGrid() {
+		if jobKey("SYNTH", q) == done[len(done)-1] {
+			p = q
+		}
+	}
+	if err := os.WriteFile(doneFile, []byte(strings.Join(done[:len(done)-1], "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	logFile := getLogFileName(logDir, startDate, endDate, "SYNTH", p, getLogExtension())
+	events, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sweep(context.Background(), confFile, runName, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if SimsComplete != 1 {
+		t.Errorf("Expected [1] simulation on resume Received [%d]", SimsComplete)
+	}
+	if rerun, err := os.ReadFile(logFile); err != nil || string(rerun) != string(events) {
+		t.Errorf("Expected the event log of the re-run job to be rewritten, [%d] bytes before Received [%d] %v", len(events), len(rerun), err)
+	}
+	db, err := sql.Open("sqlite", fmt.Sprintf("%s/results.db", outputDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var runs int
+	if err := db.QueryRow("SELECT COUNT(*) FROM runs WHERE name = ?", runName).Scan(&runs); err != nil || runs != 1 {
+		t.Errorf("Expected [1] runs row Received [%d] %v", runs, err)
+	}
+}
+
+func TestOverfitReportNeedsEveryTrial(t *testing.T) {
+	confFile := writeTestConfig(t, "")
+	runName, err := sweep(context.Background(), confFile, "", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Not created by the sqlite store
+	if err := os.RemoveAll(fmt.Sprintf("%s/SYNTH", outputDir)); err != nil {
+		t.Fatal(err)
+	}
+	writeOverfitReports(runName)
+	fileName := fmt.Sprintf("%s/SYNTH/%s_overfit.txt", outputDir, runName)
+	if _, err := os.Stat(fileName); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(fileName); err != nil {
+		t.Fatal(err)
+	}
+	trials["SYNTH"] = trials["SYNTH"][1:]
+	writeOverfitReports(runName)
+	if _, err := os.Stat(fileName); !os.IsNotExist(err) {
+		t.Errorf("Expected no overfit report from a partial set of trials Received %v", err)
+	}
+}
+
+func TestDataSourceRequired(t *testing.T) {
+	writeTestConfig(t, "")
+	delete(dataSources, "SYNTH")
+	if _, _, _, err := getData("SYNTH", dataDir); err == nil {
+		t.Error("Expected an error for an asset without a data source")
+	}
+	dataSources["SYNTH"] = "newest"
+	if _, _, _, err := getData("SYNTH", dataDir); err == nil || !strings.Contains(err.Error(), "no longer supported") {
+		t.Errorf("Expected newest to be rejected Received %v", err)
+	}
+	// A file modified later does not change which file a glob picks
+	if err := os.WriteFile(filepath.Join(dataDir, "SYNTH", "SYNTH_0.csv"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	dataSources["SYNTH"] = "SYNTH_*.csv"
+	if _, dataFile, _, err := getData("SYNTH", dataDir); err != nil || filepath.Base(dataFile) != "SYNTH_1.csv" {
+		t.Errorf("Expected the last data file by name [SYNTH_1.csv] Received [%s] %v", dataFile, err)
+	}
+}
+
+func TestValidateAssetsMissingColumns(t *testing.T) {
+	writeTestConfig(t, "")
+	data, _, _, err := getData("SYNTH", dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(dataDir, "SYNTH", "SYNTH_partial.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := data.Drop([]string{"SAR", "dP"}).WriteCSV(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	dataSources["SYNTH"] = "SYNTH_partial.csv"
+	if err := validateAssets(); err != nil {
+		t.Errorf("Expected MACD to run without SAR and dP Received %v", err)
+	}
+	Strategies = []string{"MACD", "PSAR"}
+	if err := validateAssets(); err == nil || !strings.Contains(err.Error(), "[SAR]") || strings.Contains(err.Error(), "MACD]") {
+		t.Errorf("Expected PSAR to be rejected for missing [SAR] Received %v", err)
+	}
+	Strategies = []string{"MACD"}
+	sellCondition = 6
+	if err := validateAssets(); err == nil || !strings.Contains(err.Error(), "[dP]") {
+		t.Errorf("Expected sell condition 6 to be rejected for missing [dP] Received %v", err)
+	}
+}
+
+/*
+Cancels the run once the first result is saved
+*/
+type cancelStore struct {
+	cancel context.CancelFunc
+	saved  int
+}
+
+func (c *cancelStore) Save(r simulation.Result) error {
+	c.saved += 1
+	c.cancel()
+	return nil
+}
+
+func (c *cancelStore) SaveFailure(asset string, params string, err error) error {
+	return nil
+}
+
+func (c *cancelStore) Close() error {
+	return nil
+}
+
+func TestSweepCancelled(t *testing.T) {
+	confFile := writeTestConfig(t, "[Parameters]\nmin_returns = 0.01 0.02 0.03 0.04 0.05 0.06 0.07 0.08\npercent_drops = 0.05 0.1 0.2 0.3\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := &cancelStore{cancel: cancel}
+	runName, err := sweep(ctx, confFile, "", false, store)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the run to be interrupted Received %v", err)
+	}
+	// Every result saved before the interruption is in the results file, whole
+	b, err := os.ReadFile(fmt.Sprintf("%s/SYNTH/%s.csv", outputDir, runName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	if !strings.HasSuffix(string(b), "\n") || len(lines) != store.saved || len(runCheckpoint.done) != store.saved {
+		t.Errorf("Expected [%d] complete lines and checkpointed jobs Received [%d] lines and [%d] jobs in %q", store.saved, len(lines), len(runCheckpoint.done), b)
+	}
+	for i, line := range lines {
+		if _, err := results.ParseLine(line); err != nil {
+			t.Errorf("Line %d: %v", i+1, err)
+		}
+	}
+	// The rest were cancelled, in flight or before they started
+	if numFailed != 0 || numCancelled == 0 || store.saved+numCancelled != getNumSims(assets) {
+		t.Errorf("Expected [%d] saved and cancelled simulations Received [%d] saved, [%d] cancelled and [%d] failed", getNumSims(assets), store.saved, numCancelled, numFailed)
+	}
+}
+
+func TestReadDataWindow(t *testing.T) {
+	writeTestConfig(t, "")
+	startDate, expectedBars = "15Jan2023", -1
+	data, _, _, err := getData("SYNTH", dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dates, err := data.Col("Date").Int()
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2023, 1, 15, 0, 0, 0, 0, time.UTC).Unix()
+	end := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC).Unix()
+	if int64(dates[0]) != start || int64(dates[len(dates)-1]) != end-3600 || int64(len(dates)) != (end-start)/3600 {
+		t.Errorf("Expected the bars of [15Jan2023, 01Mar2023) Received [%d] bars from [%d] to [%d]", len(dates), dates[0], dates[len(dates)-1])
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+/*
+Manifest of a run, <output_dir>/<prefix><run>.manifest.json (prefix GA_, WF_ or MC_ for the other commands).
+Written when the run starts and rewritten when it finishes, so an interrupted run still has one.
+Holds everything needed to reproduce a result: the config file contents, the build, the data file hashes and the seeds.
+*/
+
+type runManifest struct {
+	Run        string             `json:"run"`
+	Command    string             `json:"command"`
+	ConfigFile string             `json:"config_file"`
+	Config     string             `json:"config"`
+	GitCommit  string             `json:"git_commit"`
+	GitDirty   bool               `json:"git_dirty"`
+	GoVersion  string             `json:"go_version"`
+	DataFiles  []manifestDataFile `json:"data_files"`
+	Seeds      map[string]int64   `json:"seeds,omitempty"`
+	Sweep      manifestSweep      `json:"sweep"`
+	Jobs       int                `json:"jobs"`
+	Completed  int                `json:"completed"`
+	Failed     int                `json:"failed"`
+	NotRun     int                `json:"not_run"`
+	Started    time.Time          `json:"started"`
+	Resumed    []time.Time        `json:"resumed,omitempty"`
+	Finished   *time.Time         `json:"finished,omitempty"`
+	fileName   string
+}
+
+type manifestDataFile struct {
+	Asset string `json:"asset"`
+	File  string `json:"file"`
+	Hash  string `json:"hash"`
+	Error string `json:"error,omitempty"` // Why the data file could not be resolved or hashed
+}
+
+/*
+Values every job of the run is drawn from
+*/
+type manifestSweep struct {
+	Assets              []string  `json:"assets"`
+	Strategies          []string  `json:"strategies"`
+	EMAValues           []int     `json:"ema_values"`
+	ReinvestPercentages []float64 `json:"reinvest_percentages"`
+	MinReturns          []float64 `json:"min_returns"`
+	PercentDrops        []float64 `json:"percent_drops"`
+	BalanceTripwires    []float64 `json:"balance_tripwires"`
+	SellCondition       int       `json:"sell_condition"`
+	StartDate           string    `json:"start_date"`
+	EndDate             string    `json:"end_date"`
+	Interval            int64     `json:"interval"`
+	InvestAmt           float64   `json:"invest_amt"`
+	TaxRate             float64   `json:"tax_rate"`
+	Fees                float64   `json:"fees"`
+}
+
+func getManifestFile(prefix string, runName string) string {
+	return fmt.Sprintf("%s/%s%s.manifest.json", outputDir, prefix, runName)
+}
+
+/*
+Name of a run started at start, runs started within the same second (queued API jobs) get a _2, _3, ... suffix
+so they never share a manifest, checkpoint or results file
+*/
+func newRunName(prefix string, start time.Time) string {
+	name := getRunName(start)
+	runName := name
+	for n := 2; ; n++ {
+		if _, err := os.Stat(getManifestFile(prefix, runName)); err != nil {
+			return runName
+		}
+		runName = fmt.Sprintf("%s_%d", name, n)
+	}
+}
+
+func newManifest(command string, prefix string, confFile string, runName string, start time.Time, jobs int, seeds map[string]int64) (*runManifest, error) {
+	config, err := os.ReadFile(confFile)
+	if err != nil {
+		return nil, err
+	}
+	commit, dirty := getGitCommit()
+	m := &runManifest{
+		Run:        runName,
+		Command:    command,
+		ConfigFile: confFile,
+		Config:     string(config),
+		GitCommit:  commit,
+		GitDirty:   dirty,
+		GoVersion:  runtime.Version(),
+		DataFiles:  getManifestDataFiles(),
+		Seeds:      seeds,
+		Sweep: manifestSweep{
+			Assets:              assets,
+			Strategies:          Strategies,
+			EMAValues:           EMAValues,
+			ReinvestPercentages: ReinvestPercentageValues,
+			MinReturns:          MinReturnValues,
+			PercentDrops:        PercentDrop,
+			BalanceTripwires:    BalanceTripwires,
+			SellCondition:       sellCondition,
+			StartDate:           startDate,
+			EndDate:             endDate,
+			Interval:            barInterval,
+			InvestAmt:           investmentAMT,
+			TaxRate:             taxRate,
+			Fees:                fees,
+		},
+		Jobs:     jobs,
+		NotRun:   jobs,
+		Started:  start,
+		fileName: getManifestFile(prefix, runName),
+	}
+	return m, m.write()
+}
+
+/*
+Loads the manifest of an interrupted sweep and records the resume, a manifest that is missing is started over
+*/
+func resumeManifest(confFile string, runName string, start time.Time, jobs int) (*runManifest, error) {
+	m, err := loadManifest(getManifestFile("", runName))
+	if os.IsNotExist(err) {
+		return newManifest("sweep", "", confFile, runName, start, jobs, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if config, err := os.ReadFile(confFile); err == nil && string(config) != m.Config {
+		color.Yellow("%s changed since run [%s] was started, replays use the config the run was started with", confFile, runName)
+	}
+	m.Resumed = append(m.Resumed, start)
+	m.Finished = nil
+	return m, m.write()
+}
+
+func loadManifest(fileName string) (*runManifest, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	m := &runManifest{fileName: fileName}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("Invalid manifest %s: %w", fileName, err)
+	}
+	return m, nil
+}
+
+func (m *runManifest) finish(completed int, failed int, notRun int) error {
+	finished := time.Now()
+	m.Completed = completed
+	m.Failed = failed
+	m.NotRun = notRun
+	m.Finished = &finished
+	return m.write()
+}
+
+func (m *runManifest) write() error {
+	if _, err := os.Stat(outputDir); os.IsNotExist(err) {
+		err := os.MkdirAll(outputDir, 0755)
+		if err != nil {
+			return err
+		}
+	}
+	b, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so an interrupted run never leaves a partial manifest
+	tmpFile := m.fileName + ".tmp"
+	if err := os.WriteFile(tmpFile, append(b, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, m.fileName)
+}
+
+/*
+Investment a sweep results file (<output_dir>/<asset>/<run>.csv) was produced with, taken from the manifest of its run.
+Results without a manifest fall back to the configured invest_amt.
+*/
+func getResultsInvestment(resultsFile string) float64 {
+	runName := strings.TrimSuffix(filepath.Base(resultsFile), filepath.Ext(resultsFile))
+	manifestFile := filepath.Join(filepath.Dir(filepath.Dir(resultsFile)), runName+".manifest.json")
+	m, err := loadManifest(manifestFile)
+	if err != nil {
+		color.Yellow("No manifest for %s (%v), using the configured invest_amt [%g]", resultsFile, err, investmentAMT)
+		return investmentAMT
+	}
+	return m.Sweep.InvestAmt
+}
+
+func (m *runManifest) getDataFile(asset string) (manifestDataFile, bool) {
+	for _, f := range m.DataFiles {
+		if f.Asset == asset {
+			return f, true
+		}
+	}
+	return manifestDataFile{}, false
+}
+
+/*
+Data file and sha256 of every asset as resolved when the run starts
+*/
+func getManifestDataFiles() []manifestDataFile {
+	files := []manifestDataFile{}
+	for _, asset := range assets {
+		f := manifestDataFile{Asset: asset}
+		dataFileMut.Lock()
+		dataFile, err := getDataFile(asset, dataDir)
+		dataFileMut.Unlock()
+		if err == nil {
+			f.File = dataFile
+			f.Hash, err = getDataHash(dataFile)
+		}
+		if err != nil {
+			f.Error = err.Error()
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+/*
+Commit the binary was built from (see go build -buildvcs), falls back to git in the working directory
+*/
+func getGitCommit() (string, bool) {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		commit := ""
+		dirty := false
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				commit = setting.Value
+			case "vcs.modified":
+				dirty = setting.Value == "true"
+			}
+		}
+		if commit != "" {
+			return commit, dirty
+		}
+	}
+	out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", false
+	}
+	status, err := exec.Command("git", "status", "--porcelain", "--untracked-files=no").Output()
+	return strings.TrimSpace(string(out)), err == nil && len(strings.TrimSpace(string(status))) > 0
+}
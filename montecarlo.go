@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"gopkg.in/ini.v1"
+
+	"Simulations_v5/calendar"
+	"Simulations_v5/simulation"
+	"Simulations_v5/stats"
+)
+
+/*
+Monte Carlo robustness testing of a single parameter set:
+
+	trades: bootstrap the per-trade returns (with replacement), lots still open at the end included
+	bars:   block bootstrap the per-bar returns of the equity curve
+
+Each path is reduced to its final value, max drawdown and Sharpe ratio.
+*/
+
+var mcPercentiles = []float64{5, 25, 50, 75, 95}
+
+type monteCarloParams struct {
+	iterations    int      // Number of resampled paths per method
+	blockSize     int      // Number of consecutive bars in each bootstrap block
+	ruinThreshold float64  // Fraction of the initial investment at or below which a path is ruined
+	seed          int64    // Seed for the random number generator
+	params        ParamSet // Parameter set being tested
+}
+
+type mcPath struct {
+	finalValue  float64
+	maxDrawdown float64
+	sharpe      float64
+	ruined      bool
+}
+
+func runMonteCarlo(ctx context.Context, confFile string) error {
+	params, err := getMonteCarloParams(confFile)
+	if err != nil {
+		return err
+	}
+	p := params.params
+	color.Green("Running Monte Carlo (seed %d): %s EMA-%d Reinvest %g MinReturn %g PercentDrop %g BalanceTrip %g", params.seed, p.Strategy, p.EMA, p.ReinvestPerc, p.MinReturn, p.PercentDrop, p.BalanceTrip)
+	start := time.Now()
+	runName := newRunName("MC_", start)
+	manifest, err := newManifest("montecarlo", "MC_", confFile, runName, start, len(assets), map[string]int64{"montecarlo": params.seed})
+	if err != nil {
+		return err
+	}
+	// One job per asset
+	completed, failed := 0, 0
+	defer func() {
+		if err := manifest.finish(completed, failed, len(assets)-completed-failed); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}()
+	for _, asset := range assets {
+		dataFrame, dataFile, hash, err := getData(asset, dataDir)
+		if err != nil {
+			fmt.Println(err)
+			failed += 1
+			continue
+		}
+		r := runParamSet(ctx, asset, dataFrame, dataFile, hash, p)
+		if r.Err != nil {
+			fmt.Println(r.Err)
+			failed += 1
+			continue
+		}
+		rng := rand.New(rand.NewSource(params.seed))
+		tradePaths := resampleTrades(rng, r, params)
+		barPaths := bootstrapBars(rng, r, params)
+		report := fmt.Sprintf("Seed %d\n", params.seed)
+		report += fmt.Sprintf("Strategy %s,EMA %d,Reinvest %g,MinReturn %g,PercentDrop %g,BalanceTrip %g\n", p.Strategy, p.EMA, p.ReinvestPerc, p.MinReturn, p.PercentDrop, p.BalanceTrip)
+		report += fmt.Sprintf("Original,FinalValue %g,MaxDrawdown %g,Sharpe %g\n", finalEquity(r), stats.MaxDrawdown(r.Equity), stats.Sharpe(stats.Returns(r.Equity), calendar.BarsPerYear(tradingCalendar, barInterval)))
+		report += "Method,Metric,P5,P25,P50,P75,P95,Mean\n"
+		report += getDistributionString("Trades", tradePaths)
+		report += getDistributionString("Bars", barPaths)
+		report += fmt.Sprintf("Trades,ProbabilityOfRuin,%g\n", probabilityOfRuin(tradePaths))
+		report += fmt.Sprintf("Bars,ProbabilityOfRuin,%g\n", probabilityOfRuin(barPaths))
+		outDir := fmt.Sprintf("%s/%s", outputDir, asset)
+		if _, err := os.Stat(outDir); os.IsNotExist(err) {
+			err := os.MkdirAll(outDir, 0755)
+			if err != nil {
+				return err
+			}
+		}
+		fileName := fmt.Sprintf("%s/MC_%s.csv", outDir, runName)
+		if err := os.WriteFile(fileName, []byte(report), 0644); err != nil {
+			return err
+		}
+		fmt.Print(report)
+		completed += 1
+	}
+	s := fmt.Sprintf("Done in %v\nMonte Carlo results can be found in %s", time.Since(start), outputDir)
+	color.Cyan(s)
+	return nil
+}
+
+/*
+Bootstrap the returns of the closed trades and of the lots still open at the end (marked to the last close).
+Each sampled trade moves equity by its return times the share of equity its lot held when bought, so position
+sizing and profit taken out as revenue carry over. Sharpe is annualized with the number of trades per year of
+the original simulation.
+*/
+func resampleTrades(rng *rand.Rand, r simulation.Result, params monteCarloParams) []mcPath {
+	paths := make([]mcPath, 0, params.iterations)
+	trades := append(append([]simulation.Trade{}, r.Trades...), r.OpenTrades...)
+	if len(trades) == 0 {
+		return paths
+	}
+	returns := make([]float64, 0, len(trades))
+	for _, t := range trades {
+		exposure := 1.0
+		if t.BuyIndex < len(r.Equity) && r.Equity[t.BuyIndex] > 0.0 {
+			exposure = math.Min(t.Amount*t.BuyPrice/r.Equity[t.BuyIndex], 1.0)
+		}
+		returns = append(returns, exposure*t.Return())
+	}
+	years := float64(len(r.Equity)) / calendar.BarsPerYear(tradingCalendar, barInterval)
+	tradesPerYear := float64(len(trades)) / years
+	for i := 0; i < params.iterations; i++ {
+		equity := make([]float64, 0, len(returns)+1)
+		equity = append(equity, investmentAMT)
+		for range returns {
+			ret := returns[rng.Intn(len(returns))]
+			equity = append(equity, equity[len(equity)-1]*(1+ret))
+		}
+		paths = append(paths, getPath(equity, tradesPerYear, params.ruinThreshold))
+	}
+	return paths
+}
+
+/*
+Circular block bootstrap of the equity curve's bar returns
+*/
+func bootstrapBars(rng *rand.Rand, r simulation.Result, params monteCarloParams) []mcPath {
+	paths := make([]mcPath, 0, params.iterations)
+	returns := stats.Returns(r.Equity)
+	if len(returns) == 0 {
+		return paths
+	}
+	for i := 0; i < params.iterations; i++ {
+		equity := make([]float64, 0, len(returns)+1)
+		equity = append(equity, investmentAMT)
+		for len(equity) <= len(returns) {
+			start := rng.Intn(len(returns))
+			for b := 0; b < params.blockSize && len(equity) <= len(returns); b++ {
+				ret := returns[(start+b)%len(returns)]
+				equity = append(equity, equity[len(equity)-1]*(1+ret))
+			}
+		}
+		paths = append(paths, getPath(equity, calendar.BarsPerYear(tradingCalendar, barInterval), params.ruinThreshold))
+	}
+	return paths
+}
+
+func getPath(equity []float64, periodsPerYear float64, ruinThreshold float64) mcPath {
+	ruined := false
+	for _, e := range equity {
+		if e <= investmentAMT*ruinThreshold {
+			ruined = true
+			break
+		}
+	}
+	return mcPath{
+		finalValue:  equity[len(equity)-1],
+		maxDrawdown: stats.MaxDrawdown(equity),
+		sharpe:      stats.Sharpe(stats.Returns(equity), periodsPerYear),
+		ruined:      ruined,
+	}
+}
+
+func getDistributionString(method string, paths []mcPath) string {
+	finalValues := make([]float64, 0, len(paths))
+	drawdowns := make([]float64, 0, len(paths))
+	sharpes := make([]float64, 0, len(paths))
+	for _, p := range paths {
+		finalValues = append(finalValues, p.finalValue)
+		drawdowns = append(drawdowns, p.maxDrawdown)
+		sharpes = append(sharpes, p.sharpe)
+	}
+	str := ""
+	for _, metric := range []struct {
+		name   string
+		values []float64
+	}{{"FinalValue", finalValues}, {"MaxDrawdown", drawdowns}, {"Sharpe", sharpes}} {
+		str += fmt.Sprintf("%s,%s,", method, metric.name)
+		for _, p := range mcPercentiles {
+			str += fmt.Sprintf("%g,", stats.Percentile(metric.values, p))
+		}
+		str += fmt.Sprintf("%g\n", stats.Mean(metric.values))
+	}
+	return str
+}
+
+func probabilityOfRuin(paths []mcPath) float64 {
+	if len(paths) == 0 {
+		return 0.0
+	}
+	ruined := 0
+	for _, p := range paths {
+		if p.ruined {
+			ruined += 1
+		}
+	}
+	return float64(ruined) / float64(len(paths))
+}
+
+func getMonteCarloParams(confFile string) (monteCarloParams, error) {
+	cfg, err := ini.Load(confFile)
+	if err != nil {
+		fmt.Printf("Failed to read %v\n", err)
+		return monteCarloParams{}, err
+	}
+	section := cfg.Section("MonteCarlo")
+	params := monteCarloParams{
+		iterations:    section.Key("iterations").MustInt(1000),
+		blockSize:     section.Key("block_size").MustInt(24),
+		ruinThreshold: section.Key("ruin_threshold").MustFloat64(0.5),
+		seed:          section.Key("seed").MustInt64(time.Now().UnixNano()),
+	}
+	if params.iterations < 1 {
+		return monteCarloParams{}, errors.New("Config file not configured for [iterations]")
+	}
+	if params.blockSize < 1 {
+		return monteCarloParams{}, errors.New("Config file not configured for [block_size]")
+	}
+	params.params.Strategy = section.Key("strategy").String()
+	if params.params.Strategy == "" {
+		return monteCarloParams{}, errors.New("Config file not configured for [strategy]")
+	}
+	params.params.EMA, err = section.Key("ema").Int()
+	if err != nil {
+		return monteCarloParams{}, errors.New("Config file not configured for [ema]")
+	}
+	params.params.ReinvestPerc, err = section.Key("reinvest_percentage").Float64()
+	if err != nil {
+		return monteCarloParams{}, errors.New("Config file not configured for [reinvest_percentage]")
+	}
+	params.params.MinReturn, err = section.Key("min_return").Float64()
+	if err != nil {
+		return monteCarloParams{}, errors.New("Config file not configured for [min_return]")
+	}
+	params.params.PercentDrop, err = section.Key("percent_drop").Float64()
+	if err != nil {
+		return monteCarloParams{}, errors.New("Config file not configured for [percent_drop]")
+	}
+	params.params.BalanceTrip, err = section.Key("balance_tripwire").Float64()
+	if err != nil {
+		return monteCarloParams{}, errors.New("Config file not configured for [balance_tripwire]")
+	}
+	return params, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+
+	"Simulations_v5/simulation"
+)
+
+func TestResampleTrades(t *testing.T) {
+	investmentAMT, barInterval = 1000, 3600
+	params := monteCarloParams{iterations: 200, ruinThreshold: 0.5}
+	equity := []float64{1000, 1000, 1100, 1100, 900}
+	// Every lot holds the whole equity when bought
+	won := simulation.Trade{BuyIndex: 0, SellIndex: 2, BuyPrice: 10, SellPrice: 11, Amount: 100, Profit: 100}
+	lost := simulation.Trade{BuyIndex: 3, SellIndex: 4, BuyPrice: 11, SellPrice: 9, Amount: 100, Profit: -200}
+	for _, test := range []struct {
+		name       string
+		r          simulation.Result
+		drawdown   bool // Some path has a drawdown
+		finalValue float64
+	}{
+		{"Closed winning trades only", simulation.Result{Equity: equity, Trades: []simulation.Trade{won}}, false, 1100},
+		{"Open losing lot", simulation.Result{Equity: equity, Trades: []simulation.Trade{won}, OpenTrades: []simulation.Trade{lost}}, true, 0},
+	} {
+		paths := resampleTrades(rand.New(rand.NewSource(1)), test.r, params)
+		if len(paths) != params.iterations {
+			t.Fatalf("%s: expected [%d] paths Received [%d]", test.name, params.iterations, len(paths))
+		}
+		drawdown := false
+		for _, p := range paths {
+			drawdown = drawdown || p.maxDrawdown > 0
+			if test.finalValue != 0 && p.finalValue != test.finalValue {
+				t.Errorf("%s: expected final value [%g] Received [%g]", test.name, test.finalValue, p.finalValue)
+				break
+			}
+		}
+		if drawdown != test.drawdown {
+			t.Errorf("%s: expected drawdown [%v] Received [%v]", test.name, test.drawdown, drawdown)
+		}
+	}
+}
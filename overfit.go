@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/fatih/color"
+	"gopkg.in/ini.v1"
+
+	"Simulations_v5/simulation"
+	"Simulations_v5/stats"
+)
+
+/*
+Post-sweep overfitting diagnostics.
+Every completed simulation is reduced to its per period Sharpe ratio, skew, kurtosis and block moments
+so the full equity curves do not have to be kept until the sweep finishes.
+*/
+
+type trial struct {
+	params   ParamSet
+	sharpe   float64
+	skew     float64
+	kurtosis float64
+	nObs     int
+	blocks   []stats.Moments
+}
+
+type overfitParams struct {
+	blocks     int     // Number of CSCV blocks (must be even)
+	confidence float64 // Minimum deflated Sharpe ratio for the best parameter set to be considered meaningful
+}
+
+var trials = map[string][]trial{}
+var trialsMut sync.Mutex
+var overfitConf overfitParams
+
+func recordTrial(r simulation.Result, p ParamSet) {
+	if r.Err != nil || len(r.Equity) < 2 {
+		return
+	}
+	returns := stats.Returns(r.Equity)
+	t := trial{
+		params:   p,
+		sharpe:   stats.Sharpe(returns, 1),
+		skew:     stats.Skew(returns),
+		kurtosis: stats.Kurtosis(returns),
+		nObs:     len(returns),
+		blocks:   stats.BlockMoments(returns, overfitConf.blocks),
+	}
+	trialsMut.Lock()
+	defer trialsMut.Unlock()
+	trials[r.AssetName] = append(trials[r.AssetName], t)
+}
+
+/*
+Writes <output_dir>/<asset>/<outFileName>_overfit.txt for every asset in the sweep.
+Assets missing the trial of a simulation (resumed or interrupted runs, failed simulations) are skipped,
+a partial set of trials understates how many parameter sets the best one was picked from.
+*/
+func writeOverfitReports(outFileName string) {
+	trialsMut.Lock()
+	defer trialsMut.Unlock()
+	jobs := len(getParamGrid())
+	for asset, ts := range trials {
+		if len(ts) < jobs {
+			color.Yellow("[%s] No overfit report, %d of %d simulations ran to completion in this run", asset, len(ts), jobs)
+			continue
+		}
+		report, meaningful, err := getOverfitReport(ts)
+		if err != nil {
+			fmt.Printf("[%s] %v\n", asset, err)
+			continue
+		}
+		// The csv store creates <output_dir>/<asset>/, the sqlite store does not
+		dir := fmt.Sprintf("%s/%s", outputDir, asset)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fileName := fmt.Sprintf("%s/%s_overfit.txt", dir, outFileName)
+		if err := os.WriteFile(fileName, []byte(report), 0644); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		if meaningful {
+			color.Green("[%s] Best parameter set is statistically meaningful (%s)", asset, fileName)
+		} else {
+			color.Yellow("[%s] Best parameter set is likely overfit (%s)", asset, fileName)
+		}
+	}
+}
+
+func getOverfitReport(ts []trial) (string, bool, error) {
+	blocks := make([][]stats.Moments, 0, len(ts))
+	sharpes := make([]float64, 0, len(ts))
+	best := 0
+	for i, t := range ts {
+		blocks = append(blocks, t.blocks)
+		sharpes = append(sharpes, t.sharpe)
+		if t.sharpe > ts[best].sharpe {
+			best = i
+		}
+	}
+	pbo, logits, err := stats.PBO(blocks)
+	if err != nil {
+		return "", false, err
+	}
+	sd := stats.StdDev(sharpes)
+	b := ts[best]
+	dsr := stats.DeflatedSharpe(b.sharpe, sd*sd, len(ts), b.nObs, b.skew, b.kurtosis)
+	meaningful := dsr >= overfitConf.confidence && pbo < 0.5
+	p := b.params
+	report := fmt.Sprintf("Trials %d\n", len(ts))
+	report += fmt.Sprintf("Best %s,EMA %d,Reinvest %g,MinReturn %g,PercentDrop %g,BalanceTrip %g\n", p.Strategy, p.EMA, p.ReinvestPerc, p.MinReturn, p.PercentDrop, p.BalanceTrip)
+	report += fmt.Sprintf("Sharpe %g (per bar),Skew %g,Kurtosis %g,Observations %d\n", b.sharpe, b.skew, b.kurtosis, b.nObs)
+	report += fmt.Sprintf("DeflatedSharpe %g\n", dsr)
+	report += fmt.Sprintf("PBO %g (blocks %d, combinations %d, median logit %g)\n", pbo, overfitConf.blocks, len(logits), stats.Percentile(logits, 50))
+	report += fmt.Sprintf("Meaningful %t (DeflatedSharpe >= %g and PBO < 0.5)\n", meaningful, overfitConf.confidence)
+	return report, meaningful, nil
+}
+
+func getOverfitParams(confFile string) (overfitParams, error) {
+	cfg, err := ini.Load(confFile)
+	if err != nil {
+		fmt.Printf("Failed to read %v\n", err)
+		return overfitParams{}, err
+	}
+	section := cfg.Section("Overfit")
+	params := overfitParams{
+		blocks:     section.Key("blocks").MustInt(10),
+		confidence: section.Key("confidence").MustFloat64(0.95),
+	}
+	if params.blocks < 2 || params.blocks%2 != 0 {
+		return overfitParams{}, fmt.Errorf("Config file not configured for [blocks] (must be even, received %d)", params.blocks)
+	}
+	return params, nil
+}
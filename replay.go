@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/go-gota/gota/dataframe"
+	"gopkg.in/ini.v1"
+
+	"Simulations_v5/results"
+)
+
+/*
+Reruns a single result of a sweep with the config, data file and parameters it was produced with and
+verifies every field matches:
+
+	replay <run> <asset> <row>
+
+row is the 1-based line of the result in <output_dir>/<asset>/<run>.csv (or the order the results of asset
+were saved in for the sqlite store). The config is taken from <output_dir>/<run>.manifest.json.
+*/
+
+var resultFields = []string{"start", "end", "strat", "ema", "reinvest", "minReturn", "percentDrop", "balanceTrip", "buyHold",
+	"finalValue", "revenue", "tax", "fees", "numTransactions", "buys", "sells", "balances", "openReserves", "dataFile", "dataHash"}
+
+func runReplay(ctx context.Context, args []string) error {
+	if len(args) != 3 {
+		return errors.New("Usage: replay <run> <asset> <row>")
+	}
+	runName, asset := args[0], args[1]
+	row, err := strconv.Atoi(args[2])
+	if err != nil || row < 1 {
+		return fmt.Errorf("Invalid row [%s]", args[2])
+	}
+	manifest, line, rec, dataFrame, err := loadRunResult(getManifestFile("", runName), asset, row)
+	if err != nil {
+		return err
+	}
+	if commit, _ := getGitCommit(); commit != manifest.GitCommit || manifest.GitDirty {
+		color.Yellow("Run [%s] was built from commit [%s] (modified %v), replaying with [%s]", runName, manifest.GitCommit, manifest.GitDirty, commit)
+	}
+	p := recordParams(rec)
+	color.Green("Replaying [%s] row %d of run [%s]: %s", asset, row, runName, p.String())
+	r := runParamSet(ctx, asset, dataFrame, rec.DataFile, rec.DataHash, p)
+	if r.Err != nil {
+		return r.Err
+	}
+	mismatches := compareResults(line, strings.TrimSpace(r.ResultString))
+	if len(mismatches) > 0 {
+		for _, m := range mismatches {
+			color.Red(m)
+		}
+		return fmt.Errorf("[%s] Replay of row %d of run [%s] does not match: %d fields differ", asset, row, runName, len(mismatches))
+	}
+	color.Cyan("[%s] Replay of row %d of run [%s] matches", asset, row, runName)
+	return nil
+}
+
+/*
+Loads the config a sweep was started with and the row-th (1-based) result of asset with its data.
+Fails if the data file changed since the run, the returned record holds the sha256 of the data loaded.
+*/
+func loadRunResult(manifestFile string, asset string, row int) (*runManifest, string, results.Record, dataframe.DataFrame, error) {
+	manifest, err := loadManifest(manifestFile)
+	if err != nil {
+		return nil, "", results.Record{}, dataframe.DataFrame{}, fmt.Errorf("Cannot load run: %w", err)
+	}
+	runName := manifest.Run
+	if manifest.Command != "sweep" {
+		return nil, "", results.Record{}, dataframe.DataFrame{}, fmt.Errorf("Cannot load run [%s]: only sweep results can be replayed (run is %s)", runName, manifest.Command)
+	}
+	// Load the config the run was started with, the current config file may have changed since
+	f, err := os.CreateTemp("", "replay_*.ini")
+	if err != nil {
+		return nil, "", results.Record{}, dataframe.DataFrame{}, err
+	}
+	confFile := f.Name()
+	defer os.Remove(confFile)
+	if _, err := f.WriteString(manifest.Config); err != nil {
+		f.Close()
+		return nil, "", results.Record{}, dataframe.DataFrame{}, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, "", results.Record{}, dataframe.DataFrame{}, err
+	}
+	if err := loadConfig(confFile); err != nil {
+		return nil, "", results.Record{}, dataframe.DataFrame{}, err
+	}
+	lines, err := getResultLines(confFile, runName, asset)
+	if err != nil {
+		return nil, "", results.Record{}, dataframe.DataFrame{}, err
+	}
+	if row > len(lines) {
+		return nil, "", results.Record{}, dataframe.DataFrame{}, fmt.Errorf("[%s] Run [%s] has [%d] results, received row [%d]", asset, runName, len(lines), row)
+	}
+	line := lines[row-1]
+	rec, err := results.ParseLine(line)
+	if err != nil {
+		return nil, "", results.Record{}, dataframe.DataFrame{}, err
+	}
+	rec.Asset = asset
+	dataFileMut.Lock()
+	dataFrame, hash, _, err := readData(asset, rec.DataFile)
+	dataFileMut.Unlock()
+	if err != nil {
+		return nil, "", results.Record{}, dataframe.DataFrame{}, err
+	}
+	expected := rec.DataHash
+	if dataFile, ok := manifest.getDataFile(asset); ok && expected == "" && dataFile.File == rec.DataFile {
+		expected = dataFile.Hash
+	}
+	if expected != "" && hash != expected {
+		return nil, "", results.Record{}, dataframe.DataFrame{}, fmt.Errorf("[%s] Data file %s changed since the run: expected sha256 [%s] received [%s]", asset, rec.DataFile, expected, hash)
+	}
+	rec.DataHash = hash
+	return manifest, line, rec, dataFrame, nil
+}
+
+/*
+Results of asset saved by runName in the store configured in conf (a file name or the contents of a config file)
+*/
+func getResultLines(conf interface{}, runName string, asset string) ([]string, error) {
+	cfg, err := ini.Load(conf)
+	if err != nil {
+		fmt.Printf("Failed to read %v\n", err)
+		return nil, err
+	}
+	outputDir := cfg.Section("Files").Key("output_dir").String()
+	if cfg.Section("Files").Key("results_store").MustString("csv") == "sqlite" {
+		dbFile := cfg.Section("Files").Key("results_db").MustString(fmt.Sprintf("%s/results.db", outputDir))
+		return results.LoadResultStrings(dbFile, runName, asset)
+	}
+	return readLines(fmt.Sprintf("%s/%s/%s.csv", outputDir, asset, runName))
+}
+
+/*
+Fields of two result strings that differ, a hash missing from an older result is not compared
+*/
+func compareResults(expected string, received string) []string {
+	exp := strings.Split(expected, ",")
+	rec := strings.Split(received, ",")
+	if len(exp) < len(rec) {
+		rec = rec[:len(exp)]
+	}
+	if len(exp) != len(rec) {
+		return []string{fmt.Sprintf("Expected [%d] fields Received [%d]", len(exp), len(rec))}
+	}
+	mismatches := []string{}
+	for i := range exp {
+		if exp[i] != rec[i] {
+			name := strconv.Itoa(i)
+			if i < len(resultFields) {
+				name = resultFields[i]
+			}
+			mismatches = append(mismatches, fmt.Sprintf("%s: expected [%s] received [%s]", name, exp[i], rec[i]))
+		}
+	}
+	return mismatches
+}
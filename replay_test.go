@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+/*
+Config of a small sweep over a generated GBM series in a temporary directory, extra is appended as is
+*/
+func writeTestConfig(t *testing.T, extra string) string {
+	t.Helper()
+	dir := t.TempDir()
+	conf := fmt.Sprintf(`[Files]
+data_dir = %[1]s/data
+output_dir = %[1]s/out
+log_dir = %[1]s/log
+event_log = none
+
+[Simulation]
+assets = SYNTH
+start_date = 01Jan2023
+end_date = 01Mar2023
+invest_amt = 1000
+tax_rate = 0.2
+fees = 0.001
+
+[Parameters]
+strategies = MACD
+ema_values = 50
+reinvest_percentages = 0.5
+min_returns = 0.01 0.05
+percent_drops = 0.05 0.2
+balance_tripwires = 0.5
+sell_condition = 1
+
+[Data]
+interval = 1h
+
+[Synth]
+model = gbm
+seed = 1
+
+[DataSources]
+SYNTH = SYNTH_1.csv
+%[2]s`, dir, extra)
+	confFile := filepath.Join(dir, "conf.ini")
+	if err := os.WriteFile(confFile, []byte(conf), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfig(confFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := runGenerate(confFile, []string{"-asset", "SYNTH"}); err != nil {
+		t.Fatal(err)
+	}
+	return confFile
+}
+
+func TestReplayMatchesSweep(t *testing.T) {
+	ctx := context.Background()
+	confFile := writeTestConfig(t, "")
+	runName, err := sweep(ctx, confFile, "", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines, err := getResultLines(confFile, runName, "SYNTH")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != getNumSims(assets) {
+		t.Fatalf("Expected [%d] results Received [%d]", getNumSims(assets), len(lines))
+	}
+	for row := 1; row <= len(lines); row++ {
+		if err := runReplay(ctx, []string{runName, "SYNTH", strconv.Itoa(row)}); err != nil {
+			t.Errorf("Row %d: %v", row, err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strings"
+	"time"
+)
+
+const chartWidth = 1200
+const chartLeft = 80
+const chartRight = 20
+const panelGap = 36
+
+type Line struct {
+	Name   string
+	Color  string
+	Values []float64 // One value per index, NaN values are skipped
+}
+
+/*
+Marker drawn on the first line of a panel at Index
+*/
+type Marker struct {
+	Index int
+	Label string
+	Color string
+}
+
+type Panel struct {
+	Title   string
+	Height  int
+	Lines   []Line
+	Markers []Marker
+}
+
+/*
+Stacked panels sharing the x axis, times holds the unix timestamp of every index and is used for axis labels (in loc)
+*/
+func Chart(times []int64, loc *time.Location, panels []Panel) string {
+	height := 10
+	for _, p := range panels {
+		height += p.Height + panelGap
+	}
+	height += 20
+	var b strings.Builder
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" font-family=\"sans-serif\" font-size=\"11\">\n", chartWidth, height)
+	top := 10
+	for _, p := range panels {
+		renderPanel(&b, times, p, top)
+		top += p.Height + panelGap
+	}
+	renderTimeAxis(&b, times, loc, top-panelGap+16)
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+func renderPanel(b *strings.Builder, times []int64, p Panel, top int) {
+	n := len(times)
+	min := math.Inf(1)
+	max := math.Inf(-1)
+	for _, l := range p.Lines {
+		for _, v := range l.Values {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			min = math.Min(min, v)
+			max = math.Max(max, v)
+		}
+	}
+	if math.IsInf(min, 1) {
+		min, max = 0.0, 1.0
+	}
+	if max == min {
+		max = min + 1
+	}
+	plotTop := top + 16
+	plotHeight := float64(p.Height - 16)
+	xOf := func(i int) float64 {
+		if n < 2 {
+			return chartLeft
+		}
+		return chartLeft + float64(i)*float64(chartWidth-chartLeft-chartRight)/float64(n-1)
+	}
+	yOf := func(v float64) float64 {
+		return float64(plotTop) + (max-v)/(max-min)*plotHeight
+	}
+	fmt.Fprintf(b, "<text x=\"%d\" y=\"%d\" font-size=\"13\" font-weight=\"bold\">%s</text>\n", chartLeft, top+10, html.EscapeString(p.Title))
+	legendX := chartLeft + 12 + 8*len(p.Title)
+	for _, l := range p.Lines {
+		fmt.Fprintf(b, "<rect x=\"%d\" y=\"%d\" width=\"10\" height=\"3\" fill=\"%s\"/>", legendX, top+6, l.Color)
+		fmt.Fprintf(b, "<text x=\"%d\" y=\"%d\">%s</text>\n", legendX+14, top+10, html.EscapeString(l.Name))
+		legendX += 24 + 7*len(l.Name)
+	}
+	fmt.Fprintf(b, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%g\" fill=\"none\" stroke=\"#ccc\"/>\n", chartLeft, plotTop, chartWidth-chartLeft-chartRight, plotHeight)
+	for g := 0; g <= 4; g++ {
+		v := min + (max-min)*float64(g)/4
+		y := yOf(v)
+		fmt.Fprintf(b, "<line x1=\"%d\" y1=\"%.1f\" x2=\"%d\" y2=\"%.1f\" stroke=\"#eee\"/>", chartLeft, y, chartWidth-chartRight, y)
+		fmt.Fprintf(b, "<text x=\"%d\" y=\"%.1f\" text-anchor=\"end\">%s</text>\n", chartLeft-4, y+4, formatValue(v))
+	}
+	for _, l := range p.Lines {
+		b.WriteString("<path d=\"")
+		move := true
+		for i, v := range l.Values {
+			if i >= n {
+				break
+			}
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				move = true
+				continue
+			}
+			if move {
+				fmt.Fprintf(b, "M%.1f %.1f", xOf(i), yOf(v))
+				move = false
+			} else {
+				fmt.Fprintf(b, "L%.1f %.1f", xOf(i), yOf(v))
+			}
+		}
+		fmt.Fprintf(b, "\" fill=\"none\" stroke=\"%s\" stroke-width=\"1\"/>\n", l.Color)
+	}
+	if len(p.Lines) > 0 {
+		base := p.Lines[0].Values
+		for _, m := range p.Markers {
+			if m.Index < 0 || m.Index >= len(base) || m.Index >= n || math.IsNaN(base[m.Index]) {
+				continue
+			}
+			fmt.Fprintf(b, "<circle cx=\"%.1f\" cy=\"%.1f\" r=\"3.5\" fill=\"%s\" stroke=\"#000\" stroke-width=\"0.5\"><title>%s</title></circle>\n", xOf(m.Index), yOf(base[m.Index]), m.Color, html.EscapeString(m.Label))
+		}
+	}
+}
+
+func renderTimeAxis(b *strings.Builder, times []int64, loc *time.Location, y int) {
+	n := len(times)
+	if n == 0 {
+		return
+	}
+	ticks := 6
+	for t := 0; t <= ticks; t++ {
+		i := t * (n - 1) / ticks
+		x := float64(chartLeft)
+		if n > 1 {
+			x += float64(i) * float64(chartWidth-chartLeft-chartRight) / float64(n-1)
+		}
+		label := time.Unix(times[i], 0).In(loc).Format("02Jan2006 15:04 MST")
+		fmt.Fprintf(b, "<text x=\"%.1f\" y=\"%d\" text-anchor=\"middle\">%s</text>\n", x, y, label)
+	}
+}
@@ -0,0 +1,92 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strings"
+)
+
+const cellWidth = 70
+const cellHeight = 28
+const axisMargin = 110
+
+/*
+Heatmap grid where values[y][x] is the cell for xs[x] and ys[y] (NaN cells are left empty).
+The cell at (highlightX, highlightY) is outlined, pass -1 to outline nothing.
+*/
+type Heatmap struct {
+	Title      string
+	XLabel     string
+	YLabel     string
+	Xs         []string
+	Ys         []string
+	Values     [][]float64
+	HighlightX int
+	HighlightY int
+}
+
+func (h Heatmap) SVG() string {
+	min := math.Inf(1)
+	max := math.Inf(-1)
+	for _, row := range h.Values {
+		for _, v := range row {
+			if math.IsNaN(v) {
+				continue
+			}
+			min = math.Min(min, v)
+			max = math.Max(max, v)
+		}
+	}
+	width := axisMargin + len(h.Xs)*cellWidth + 20
+	height := 40 + len(h.Ys)*cellHeight + 60
+	var b strings.Builder
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" font-family=\"sans-serif\" font-size=\"11\">\n", width, height)
+	fmt.Fprintf(&b, "<text x=\"%d\" y=\"20\" font-size=\"14\">%s</text>\n", axisMargin, html.EscapeString(h.Title))
+	for y, yLabel := range h.Ys {
+		top := 40 + y*cellHeight
+		fmt.Fprintf(&b, "<text x=\"%d\" y=\"%d\" text-anchor=\"end\">%s</text>\n", axisMargin-6, top+cellHeight/2+4, html.EscapeString(yLabel))
+		for x := range h.Xs {
+			left := axisMargin + x*cellWidth
+			v := math.NaN()
+			if y < len(h.Values) && x < len(h.Values[y]) {
+				v = h.Values[y][x]
+			}
+			t := 0.5
+			if max > min {
+				t = (v - min) / (max - min)
+			}
+			if math.IsNaN(v) {
+				t = math.NaN()
+			}
+			fmt.Fprintf(&b, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\" stroke=\"#fff\"/>\n", left, top, cellWidth, cellHeight, scaleColor(t))
+			if !math.IsNaN(v) {
+				fmt.Fprintf(&b, "<text x=\"%d\" y=\"%d\" text-anchor=\"middle\">%s</text>\n", left+cellWidth/2, top+cellHeight/2+4, formatValue(v))
+			}
+		}
+	}
+	if h.HighlightX >= 0 && h.HighlightY >= 0 {
+		fmt.Fprintf(&b, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"none\" stroke=\"#000\" stroke-width=\"3\"/>\n", axisMargin+h.HighlightX*cellWidth, 40+h.HighlightY*cellHeight, cellWidth, cellHeight)
+	}
+	bottom := 40 + len(h.Ys)*cellHeight
+	for x, xLabel := range h.Xs {
+		fmt.Fprintf(&b, "<text x=\"%d\" y=\"%d\" text-anchor=\"middle\">%s</text>\n", axisMargin+x*cellWidth+cellWidth/2, bottom+16, html.EscapeString(xLabel))
+	}
+	fmt.Fprintf(&b, "<text x=\"%d\" y=\"%d\" text-anchor=\"middle\" font-size=\"12\">%s</text>\n", axisMargin+len(h.Xs)*cellWidth/2, bottom+40, html.EscapeString(h.XLabel))
+	fmt.Fprintf(&b, "<text x=\"12\" y=\"%d\" font-size=\"12\" transform=\"rotate(-90 12 %d)\" text-anchor=\"middle\">%s</text>\n", 40+len(h.Ys)*cellHeight/2, 40+len(h.Ys)*cellHeight/2, html.EscapeString(h.YLabel))
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+func formatValue(v float64) string {
+	abs := math.Abs(v)
+	switch {
+	case abs >= 100000:
+		return fmt.Sprintf("%.3g", v)
+	case abs >= 100:
+		return fmt.Sprintf("%.0f", v)
+	case abs >= 1:
+		return fmt.Sprintf("%.2f", v)
+	}
+	return fmt.Sprintf("%.3f", v)
+}
@@ -0,0 +1,78 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strings"
+)
+
+/*
+Self-contained SVG/HTML output (no scripts, stylesheets or fonts loaded from the network)
+*/
+
+const pageStyle = `body{font-family:sans-serif;margin:20px;background:#fafafa;color:#222}
+h1{font-size:20px}h2{font-size:16px;margin-top:28px}
+table{border-collapse:collapse;margin:8px 0}td,th{border:1px solid #ccc;padding:3px 8px;font-size:13px;text-align:right}
+svg{background:#fff;border:1px solid #ddd}`
+
+/*
+Wraps body in a complete HTML document
+*/
+func Page(title string, body string) string {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&b, "<title>%s</title>\n", html.EscapeString(title))
+	fmt.Fprintf(&b, "<style>%s</style>\n", pageStyle)
+	b.WriteString("</head>\n<body>\n")
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", html.EscapeString(title))
+	b.WriteString(body)
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+/*
+HTML table with a header row, cells are escaped
+*/
+func Table(header []string, rows [][]string) string {
+	var b strings.Builder
+	b.WriteString("<table>\n<tr>")
+	for _, h := range header {
+		fmt.Fprintf(&b, "<th>%s</th>", html.EscapeString(h))
+	}
+	b.WriteString("</tr>\n")
+	for _, row := range rows {
+		b.WriteString("<tr>")
+		for _, cell := range row {
+			fmt.Fprintf(&b, "<td>%s</td>", html.EscapeString(cell))
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</table>\n")
+	return b.String()
+}
+
+/*
+Color on a red -> yellow -> green scale for t in [0, 1]
+*/
+func scaleColor(t float64) string {
+	if math.IsNaN(t) {
+		return "#eeeeee"
+	}
+	t = math.Max(0.0, math.Min(1.0, t))
+	red := [3]float64{215, 48, 39}
+	yellow := [3]float64{254, 224, 139}
+	green := [3]float64{26, 152, 80}
+	from, to := red, yellow
+	if t > 0.5 {
+		from, to = yellow, green
+		t = (t - 0.5) * 2
+	} else {
+		t = t * 2
+	}
+	c := [3]int{}
+	for i := range c {
+		c[i] = int(math.Round(from[i] + (to[i]-from[i])*t))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", c[0], c[1], c[2])
+}
@@ -0,0 +1,64 @@
+package report
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChart(t *testing.T) {
+	times := []int64{0, 3600, 7200, 10800, 14400}
+	panels := []Panel{
+		{Title: "Price <BTC>", Height: 100, Lines: []Line{
+			{Name: "Close", Color: "#000", Values: []float64{1, 2, 3, 4, 5}},
+			{Name: "EMA & co", Color: "#f00", Values: []float64{math.NaN(), 2, math.NaN(), 4, 5}},
+		}, Markers: []Marker{{Index: 1, Label: "BUY <1>", Color: "green"}, {Index: 9, Label: "out of range", Color: "red"}}},
+		{Title: "Drawdown", Height: 60, Lines: []Line{{Name: "Drawdown", Color: "#00f", Values: []float64{0, 0, 0, 0, 0}}}},
+	}
+	svg := Chart(times, time.UTC, panels)
+	if !strings.HasPrefix(svg, "<svg ") || !strings.HasSuffix(svg, "</svg>\n") {
+		t.Fatalf("Expected a single svg element Received %s", svg)
+	}
+	paths := []string{}
+	for _, line := range strings.Split(svg, "\n") {
+		if strings.HasPrefix(line, "<path d=\"") {
+			paths = append(paths, strings.SplitN(line[len("<path d=\""):], "\"", 2)[0])
+		}
+	}
+	// NaN values break the line, a new M command starts after each gap
+	expected := []struct{ moves, lines int }{{1, 4}, {2, 1}, {1, 4}}
+	if len(paths) != len(expected) {
+		t.Fatalf("Expected [%d] paths Received %v", len(expected), paths)
+	}
+	for i, e := range expected {
+		if m, l := strings.Count(paths[i], "M"), strings.Count(paths[i], "L"); m != e.moves || l != e.lines {
+			t.Errorf("Path %d: expected [%d] M and [%d] L commands Received [%d] and [%d] in %s", i, e.moves, e.lines, m, l, paths[i])
+		}
+	}
+	for _, label := range []string{"01Jan1970 00:00 UTC", "01Jan1970 04:00 UTC", "Price &lt;BTC&gt;", "EMA &amp; co", "<title>BUY &lt;1&gt;</title>"} {
+		if !strings.Contains(svg, label) {
+			t.Errorf("Expected %q in the chart", label)
+		}
+	}
+	if strings.Contains(svg, "<BTC>") || strings.Contains(svg, "out of range") {
+		t.Error("Expected escaped titles and no marker outside the data")
+	}
+}
+
+func TestPage(t *testing.T) {
+	page := Page("Run <1> & more", Table([]string{"Key", "<b>"}, [][]string{{"a", "x < y"}}))
+	for _, s := range []string{
+		"<title>Run &lt;1&gt; &amp; more</title>",
+		"<h1>Run &lt;1&gt; &amp; more</h1>",
+		"<tr><th>Key</th><th>&lt;b&gt;</th></tr>",
+		"<tr><td>a</td><td>x &lt; y</td></tr>",
+	} {
+		if !strings.Contains(page, s) {
+			t.Errorf("Expected %q in %s", s, page)
+		}
+	}
+	if !strings.HasPrefix(page, "<!DOCTYPE html>") || !strings.HasSuffix(page, "</html>\n") || strings.Count(page, "<table>") != 1 {
+		t.Errorf("Expected a complete document with one table Received %s", page)
+	}
+}
@@ -0,0 +1,184 @@
+package results
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+/*
+Reads the comma separated results written by the simulation package (see getResultString):
+
+	start,end,strat,ema,reinvest,minReturn,percentDrop,balanceTrip,buyHold,finalValue,revenue,tax,fees,numTransactions,[buys],[sells],[balances],[openReserves],dataFile[,dataHash]
+
+dataHash (sha256 of the data file) is missing from results written before it was recorded.
+*/
+
+var Metrics = []string{"final_value", "profit", "excess", "revenue", "tax", "fees", "transactions"}
+
+// Metrics where the lowest value is the best, higher is better for the others
+var lowerIsBetter = map[string]bool{"tax": true, "fees": true, "transactions": true}
+
+type Record struct {
+	Asset           string  `json:"asset"`               // Name of asset (directory the results file is in)
+	Start           int     `json:"start"`               // Timestamp of the first row of data
+	End             int     `json:"end"`                 // Timestamp of the last row of data
+	Strategy        string  `json:"strategy"`            // Strategy simulated
+	EMA             int     `json:"ema"`                 // EMA used for strategy
+	ReinvestPerc    float64 `json:"reinvest_percentage"` // Percentage of profit reallocated to capital
+	MinReturn       float64 `json:"min_return"`          // Min return for sell
+	PercentDrop     float64 `json:"percent_drop"`        // Percentage of negative return where reserves are opened
+	BalanceTrip     float64 `json:"balance_tripwire"`    // Tripwire for capital and reserves to be balanced
+	BuyHold         float64 `json:"buy_hold"`            // Profit of buying and holding over the same data (in USD)
+	FinalValue      float64 `json:"final_value"`         // Capital + reserves + asset at the end of the simulation (in USD)
+	Revenue         float64 `json:"revenue"`             // Revenue taken out of the simulation (in USD)
+	Tax             float64 `json:"tax"`                 // Taxes accrued (in USD)
+	Fees            float64 `json:"fees"`                // Fees accrued (in USD)
+	NumTransactions int     `json:"transactions"`        // Number of transactions
+	Buys            []int   `json:"buys"`                // Indices of buys
+	Sells           []int   `json:"sells"`               // Indices of sells
+	Balances        []int   `json:"balances"`            // Indices of balances
+	OpenReserves    []int   `json:"open_reserves"`       // Indices of opened reserves
+	DataFile        string  `json:"data_file"`           // Data file used for the simulation
+	DataHash        string  `json:"data_hash"`           // sha256 of the data file (empty for older results)
+}
+
+/*
+Loads every result in a results file, the asset name is taken from the parent directory
+*/
+func Load(fileName string) ([]Record, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	asset := filepath.Base(filepath.Dir(fileName))
+	records := []Record{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum += 1
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		r, err := ParseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", fileName, lineNum, err)
+		}
+		r.Asset = asset
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func ParseLine(line string) (Record, error) {
+	fields := strings.Split(strings.TrimSpace(line), ",")
+	if len(fields) < 19 {
+		return Record{}, fmt.Errorf("Expected [19] fields Received [%d]", len(fields))
+	}
+	r := Record{}
+	var err error
+	ints := []struct {
+		dst *int
+		idx int
+	}{{&r.Start, 0}, {&r.End, 1}, {&r.EMA, 3}, {&r.NumTransactions, 13}}
+	for _, i := range ints {
+		*i.dst, err = strconv.Atoi(fields[i.idx])
+		if err != nil {
+			return Record{}, err
+		}
+	}
+	floats := []struct {
+		dst *float64
+		idx int
+	}{{&r.ReinvestPerc, 4}, {&r.MinReturn, 5}, {&r.PercentDrop, 6}, {&r.BalanceTrip, 7}, {&r.BuyHold, 8}, {&r.FinalValue, 9}, {&r.Revenue, 10}, {&r.Tax, 11}, {&r.Fees, 12}}
+	for _, f := range floats {
+		*f.dst, err = strconv.ParseFloat(fields[f.idx], 64)
+		if err != nil {
+			return Record{}, err
+		}
+	}
+	r.Strategy = fields[2]
+	indices := []struct {
+		dst *[]int
+		idx int
+	}{{&r.Buys, 14}, {&r.Sells, 15}, {&r.Balances, 16}, {&r.OpenReserves, 17}}
+	for _, i := range indices {
+		*i.dst, err = parseIndices(fields[i.idx])
+		if err != nil {
+			return Record{}, err
+		}
+	}
+	r.DataFile = fields[18]
+	if len(fields) > 19 {
+		r.DataHash = fields[19]
+	}
+	return r, nil
+}
+
+func parseIndices(field string) ([]int, error) {
+	indices := []int{}
+	for _, str := range strings.Fields(strings.Trim(field, "[]")) {
+		i, err := strconv.Atoi(str)
+		if err != nil {
+			return nil, err
+		}
+		indices = append(indices, i)
+	}
+	return indices, nil
+}
+
+/*
+Profit over the initial investment including revenue taken out of the simulation
+*/
+func (r Record) Profit(investment float64) float64 {
+	return r.FinalValue + r.Revenue - investment
+}
+
+/*
+Value of the named metric (see Metrics) for the record
+*/
+func (r Record) Metric(name string, investment float64) (float64, error) {
+	switch name {
+	case "final_value":
+		return r.FinalValue, nil
+	case "profit":
+		return r.Profit(investment), nil
+	case "excess":
+		return r.Profit(investment) - r.BuyHold, nil
+	case "revenue":
+		return r.Revenue, nil
+	case "tax":
+		return r.Tax, nil
+	case "fees":
+		return r.Fees, nil
+	case "transactions":
+		return float64(r.NumTransactions), nil
+	}
+	return 0.0, fmt.Errorf("Invalid metric [%s] (valid: %s)", name, strings.Join(Metrics, " "))
+}
+
+/*
+Whether the lowest value of the named metric is the best (tax, fees and transactions)
+*/
+func LowerIsBetter(metric string) bool {
+	return lowerIsBetter[metric]
+}
+
+/*
+Whether a is a better value of the named metric than b
+*/
+func Better(metric string, a float64, b float64) bool {
+	if LowerIsBetter(metric) {
+		return a < b
+	}
+	return a > b
+}
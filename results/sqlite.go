@@ -0,0 +1,286 @@
+package results
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"Simulations_v5/simulation"
+)
+
+/*
+SQLite results store:
+
+	runs:       one row per run, a resumed run keeps its row
+	param_sets: distinct parameter sets, identical configurations share a row
+	data_files: data file metadata (path, size, modification time, covered timestamps, latest content hash)
+	results:    one row per simulation, joins runs, param_sets and data_files (with the data file's hash at the time)
+	trades:     every lot sold by a simulation
+	events:     BUY/SELL/BAL/OR indices of a simulation
+	failures:   simulations of a run that did not complete
+*/
+
+const schema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id             INTEGER PRIMARY KEY,
+	name           TEXT NOT NULL,
+	started        TEXT NOT NULL,
+	start_date     TEXT,
+	end_date       TEXT,
+	invest_amt     REAL,
+	tax_rate       REAL,
+	fees           REAL,
+	sell_condition INTEGER
+);
+CREATE TABLE IF NOT EXISTS param_sets (
+	id                  INTEGER PRIMARY KEY,
+	strategy            TEXT NOT NULL,
+	sell_condition      INTEGER NOT NULL,
+	ema                 INTEGER NOT NULL,
+	reinvest_percentage REAL NOT NULL,
+	min_return          REAL NOT NULL,
+	percent_drop        REAL NOT NULL,
+	balance_tripwire    REAL NOT NULL,
+	invest_amt          REAL NOT NULL,
+	tax_rate            REAL NOT NULL,
+	fees                REAL NOT NULL,
+	UNIQUE(strategy, sell_condition, ema, reinvest_percentage, min_return, percent_drop, balance_tripwire, invest_amt, tax_rate, fees)
+);
+CREATE TABLE IF NOT EXISTS data_files (
+	id              INTEGER PRIMARY KEY,
+	path            TEXT NOT NULL UNIQUE,
+	size            INTEGER,
+	modified        TEXT,
+	first_timestamp INTEGER,
+	last_timestamp  INTEGER,
+	hash            TEXT
+);
+CREATE TABLE IF NOT EXISTS results (
+	id            INTEGER PRIMARY KEY,
+	run_id        INTEGER NOT NULL REFERENCES runs(id),
+	param_set_id  INTEGER NOT NULL REFERENCES param_sets(id),
+	data_file_id  INTEGER NOT NULL REFERENCES data_files(id),
+	asset         TEXT NOT NULL,
+	buy_hold      REAL,
+	final_value   REAL,
+	revenue       REAL,
+	tax           REAL,
+	fees          REAL,
+	transactions  INTEGER,
+	result_string TEXT,
+	data_hash     TEXT,
+	UNIQUE(run_id, param_set_id, data_file_id, asset)
+);
+CREATE TABLE IF NOT EXISTS trades (
+	id         INTEGER PRIMARY KEY,
+	result_id  INTEGER NOT NULL REFERENCES results(id),
+	buy_index  INTEGER,
+	sell_index INTEGER,
+	buy_price  REAL,
+	sell_price REAL,
+	amount     REAL,
+	profit     REAL
+);
+CREATE TABLE IF NOT EXISTS events (
+	id        INTEGER PRIMARY KEY,
+	result_id INTEGER NOT NULL REFERENCES results(id),
+	kind      TEXT NOT NULL,
+	bar_index INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS failures (
+	id     INTEGER PRIMARY KEY,
+	run_id INTEGER NOT NULL REFERENCES runs(id),
+	asset  TEXT NOT NULL,
+	params TEXT NOT NULL,
+	error  TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS results_param_set ON results(param_set_id);
+CREATE INDEX IF NOT EXISTS trades_result ON trades(result_id);
+CREATE INDEX IF NOT EXISTS events_result ON events(result_id);
+`
+
+/*
+Columns added after the first schema, applied to databases created before them
+*/
+var migrations = []string{
+	"ALTER TABLE data_files ADD COLUMN hash TEXT",
+	"ALTER TABLE results ADD COLUMN data_hash TEXT",
+}
+
+type SQLiteStore struct {
+	db    *sql.DB
+	run   Run
+	runID int64
+}
+
+func NewSQLiteStore(fileName string, run Run) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", fileName)
+	if err != nil {
+		return nil, err
+	}
+	// Writes are serialized by the caller, a single connection avoids SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	runID, err := getRunID(db, run)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStore{db, run, runID}, nil
+}
+
+/*
+Row of run, inserted unless a run of the same name (the one being resumed) already has one
+*/
+func getRunID(db *sql.DB, run Run) (int64, error) {
+	var runID int64
+	err := db.QueryRow("SELECT id FROM runs WHERE name = ? ORDER BY id LIMIT 1", run.Name).Scan(&runID)
+	if err != sql.ErrNoRows {
+		return runID, err
+	}
+	res, err := db.Exec("INSERT INTO runs (name, started, start_date, end_date, invest_amt, tax_rate, fees, sell_condition) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		run.Name, run.Started.Format(time.RFC3339), run.StartDate, run.EndDate, run.InvestAmt, run.TaxRate, run.Fees, run.SellCondition)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+/*
+Adds missing columns, a column that already exists is not an error
+*/
+func migrate(db *sql.DB) error {
+	for _, m := range migrations {
+		if _, err := db.Exec(m); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Save(r simulation.Result) error {
+	rec, err := ParseLine(r.ResultString)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	paramSetID, err := s.getParamSetID(tx, rec)
+	if err != nil {
+		return err
+	}
+	dataFileID, err := getDataFileID(tx, rec)
+	if err != nil {
+		return err
+	}
+	// A job saved again (e.g. re-run after -resume) keeps its row, its trades and events are replaced
+	_, err = tx.Exec("INSERT INTO results (run_id, param_set_id, data_file_id, asset, buy_hold, final_value, revenue, tax, fees, transactions, result_string, data_hash) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT(run_id, param_set_id, data_file_id, asset) DO UPDATE SET buy_hold = excluded.buy_hold, final_value = excluded.final_value, revenue = excluded.revenue, tax = excluded.tax, fees = excluded.fees, transactions = excluded.transactions, result_string = excluded.result_string, data_hash = excluded.data_hash",
+		s.runID, paramSetID, dataFileID, r.AssetName, rec.BuyHold, rec.FinalValue, rec.Revenue, rec.Tax, rec.Fees, rec.NumTransactions, r.ResultString, rec.DataHash)
+	if err != nil {
+		return err
+	}
+	var resultID int64
+	err = tx.QueryRow("SELECT id FROM results WHERE run_id = ? AND param_set_id = ? AND data_file_id = ? AND asset = ?", s.runID, paramSetID, dataFileID, r.AssetName).Scan(&resultID)
+	if err != nil {
+		return err
+	}
+	for _, table := range []string{"trades", "events"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE result_id = ?", resultID); err != nil {
+			return err
+		}
+	}
+	for _, t := range r.Trades {
+		_, err := tx.Exec("INSERT INTO trades (result_id, buy_index, sell_index, buy_price, sell_price, amount, profit) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			resultID, t.BuyIndex, t.SellIndex, t.BuyPrice, t.SellPrice, t.Amount, t.Profit)
+		if err != nil {
+			return err
+		}
+	}
+	events := []struct {
+		kind    string
+		indices []int
+	}{{"BUY", rec.Buys}, {"SELL", rec.Sells}, {"BAL", rec.Balances}, {"OR", rec.OpenReserves}}
+	for _, e := range events {
+		for _, index := range e.indices {
+			if _, err := tx.Exec("INSERT INTO events (result_id, kind, bar_index) VALUES (?, ?, ?)", resultID, e.kind, index); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) SaveFailure(asset string, params string, err error) error {
+	_, dbErr := s.db.Exec("INSERT INTO failures (run_id, asset, params, error) VALUES (?, ?, ?, ?)", s.runID, asset, params, err.Error())
+	return dbErr
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) getParamSetID(tx *sql.Tx, rec Record) (int64, error) {
+	args := []interface{}{rec.Strategy, s.run.SellCondition, rec.EMA, rec.ReinvestPerc, rec.MinReturn, rec.PercentDrop, rec.BalanceTrip, s.run.InvestAmt, s.run.TaxRate, s.run.Fees}
+	_, err := tx.Exec("INSERT OR IGNORE INTO param_sets (strategy, sell_condition, ema, reinvest_percentage, min_return, percent_drop, balance_tripwire, invest_amt, tax_rate, fees) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", args...)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = tx.QueryRow("SELECT id FROM param_sets WHERE strategy = ? AND sell_condition = ? AND ema = ? AND reinvest_percentage = ? AND min_return = ? AND percent_drop = ? AND balance_tripwire = ? AND invest_amt = ? AND tax_rate = ? AND fees = ?", args...).Scan(&id)
+	return id, err
+}
+
+func getDataFileID(tx *sql.Tx, rec Record) (int64, error) {
+	var size int64
+	modified := ""
+	if fi, err := os.Stat(rec.DataFile); err == nil {
+		size = fi.Size()
+		modified = fi.ModTime().UTC().Format(time.RFC3339)
+	}
+	_, err := tx.Exec("INSERT INTO data_files (path, size, modified, first_timestamp, last_timestamp, hash) VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT(path) DO UPDATE SET size = excluded.size, modified = excluded.modified, first_timestamp = excluded.first_timestamp, last_timestamp = excluded.last_timestamp, hash = excluded.hash",
+		rec.DataFile, size, modified, rec.Start, rec.End, rec.DataHash)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = tx.QueryRow("SELECT id FROM data_files WHERE path = ?", rec.DataFile).Scan(&id)
+	return id, err
+}
+
+/*
+Result strings of asset saved by every run named runName (a resumed run has several), in the order they were saved
+*/
+func LoadResultStrings(fileName string, runName string, asset string) ([]string, error) {
+	db, err := sql.Open("sqlite", fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	rows, err := db.Query("SELECT results.result_string FROM results JOIN runs ON runs.id = results.run_id WHERE runs.name = ? AND results.asset = ? ORDER BY results.id", runName, asset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	lines := []string{}
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, err
+		}
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	return lines, rows.Err()
+}
@@ -0,0 +1,61 @@
+package results
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"Simulations_v5/simulation"
+)
+
+func TestSQLiteStoreSaveAgain(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "results.db")
+	run := Run{Name: "TEST", Started: time.Now(), StartDate: "01Jan2023", EndDate: "01Mar2023", InvestAmt: 1000}
+	store, err := NewSQLiteStore(fileName, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	r := simulation.Result{
+		AssetName:    "TEST",
+		ResultString: "0,3600,MACD,50,0.5,0.01,-0.05,0.5,1000,1100,10,2,1,2,[1 5],[5],[],[],test.csv,abc",
+		Trades:       []simulation.Trade{{BuyIndex: 1, SellIndex: 5}, {BuyIndex: 1, SellIndex: 5}},
+	}
+	if err := store.Save(r); err != nil {
+		t.Fatal(err)
+	}
+	var resultID int64
+	if err := store.db.QueryRow("SELECT id FROM results").Scan(&resultID); err != nil {
+		t.Fatal(err)
+	}
+	// The job is saved again with one trade, e.g. after -resume
+	r.Trades = r.Trades[:1]
+	if err := store.Save(r); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		query string
+		count int
+	}{
+		{"SELECT COUNT(*) FROM results WHERE id = ?", 1},
+		{"SELECT COUNT(*) FROM results WHERE id != ?", 0},
+		{"SELECT COUNT(*) FROM trades WHERE result_id = ?", 1},
+		{"SELECT COUNT(*) FROM events WHERE result_id = ?", 3},
+		{"SELECT COUNT(*) FROM trades WHERE result_id != ?", 0},
+		{"SELECT COUNT(*) FROM events WHERE result_id != ?", 0},
+	} {
+		var count int
+		if err := store.db.QueryRow(test.query, resultID).Scan(&count); err != nil || count != test.count {
+			t.Errorf("%s: expected [%d] Received [%d] %v", test.query, test.count, count, err)
+		}
+	}
+	// Reopening the store for the same run (-resume) keeps its row
+	resumed, err := NewSQLiteStore(fileName, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.Close()
+	if resumed.runID != store.runID {
+		t.Errorf("Expected run id [%d] on resume Received [%d]", store.runID, resumed.runID)
+	}
+}
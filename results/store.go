@@ -0,0 +1,172 @@
+package results
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"Simulations_v5/simulation"
+)
+
+/*
+Destination for the results of a run (see [Files] results_store)
+
+	csv:    <output_dir>/<asset>/<run>.csv, one line per result (default)
+	        <output_dir>/<asset>/<run>_failures.csv, one line per failed simulation
+	sqlite: single database shared by every run (see sqlite.go)
+
+SaveFailure records a simulation that did not complete, params identifies the parameter set.
+*/
+type Store interface {
+	Save(r simulation.Result) error
+	SaveFailure(asset string, params string, err error) error
+	Close() error
+}
+
+/*
+Settings shared by every simulation in a run
+*/
+type Run struct {
+	Name          string
+	Started       time.Time
+	StartDate     string
+	EndDate       string
+	InvestAmt     float64
+	TaxRate       float64
+	Fees          float64
+	SellCondition int
+}
+
+type CSVStore struct {
+	outputDir string
+	run       Run
+}
+
+func NewCSVStore(outputDir string, run Run) *CSVStore {
+	return &CSVStore{outputDir, run}
+}
+
+func (c *CSVStore) Save(r simulation.Result) error {
+	return c.appendLine(r.AssetName, c.run.Name, r.ResultString)
+}
+
+/*
+params,error with newlines and commas removed from the error
+*/
+func (c *CSVStore) SaveFailure(asset string, params string, err error) error {
+	msg := strings.NewReplacer("\n", " ", ",", ";").Replace(err.Error())
+	return c.appendLine(asset, c.run.Name+"_failures", fmt.Sprintf("%s,%s\n", params, msg))
+}
+
+func (c *CSVStore) appendLine(asset string, name string, line string) error {
+	outDir := fmt.Sprintf("%s/%s", c.outputDir, asset)
+	if _, err := os.Stat(outDir); os.IsNotExist(err) {
+		err := os.MkdirAll(outDir, 0755)
+		if err != nil {
+			return err
+		}
+	}
+	fileName := fmt.Sprintf("%s/%s.csv", outDir, name)
+	f, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write([]byte(line)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (c *CSVStore) Close() error {
+	return nil
+}
+
+/*
+Saves to every store, every store is tried even if one fails
+*/
+type MultiStore struct {
+	stores []Store
+}
+
+func NewMultiStore(stores ...Store) *MultiStore {
+	return &MultiStore{stores}
+}
+
+func (m *MultiStore) Save(r simulation.Result) error {
+	errs := []error{}
+	for _, s := range m.stores {
+		errs = append(errs, s.Save(r))
+	}
+	return errors.Join(errs...)
+}
+
+func (m *MultiStore) SaveFailure(asset string, params string, err error) error {
+	errs := []error{}
+	for _, s := range m.stores {
+		errs = append(errs, s.SaveFailure(asset, params, err))
+	}
+	return errors.Join(errs...)
+}
+
+func (m *MultiStore) Close() error {
+	errs := []error{}
+	for _, s := range m.stores {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
+
+type Failure struct {
+	Asset  string `json:"asset"`
+	Params string `json:"params"` // strat,ema,reinvest,minReturn,percentDrop,balanceTrip
+	Error  string `json:"error"`
+}
+
+/*
+Keeps the results of a run in memory, safe for concurrent use
+*/
+type MemoryStore struct {
+	mut      sync.Mutex
+	records  []Record
+	failures []Failure
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (m *MemoryStore) Save(r simulation.Result) error {
+	rec, err := ParseLine(r.ResultString)
+	if err != nil {
+		return err
+	}
+	rec.Asset = r.AssetName
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	m.records = append(m.records, rec)
+	return nil
+}
+
+func (m *MemoryStore) SaveFailure(asset string, params string, err error) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	m.failures = append(m.failures, Failure{asset, params, err.Error()})
+	return nil
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+/*
+Copies of the results and failures saved so far, in the order they were saved
+*/
+func (m *MemoryStore) Results() ([]Record, []Failure) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	return append([]Record{}, m.records...), append([]Failure{}, m.failures...)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+
+	"Simulations_v5/results"
+	"Simulations_v5/simulation"
+)
+
+/*
+Runs API of the dashboard (see dashboard), sweeps with a manifest in the output_dir serve was started with:
+
+	GET /api/v1/runs                                   every sweep, newest first
+	GET /api/v1/runs/<run>/results                     every result of a run with its metrics
+	GET /api/v1/runs/<run>/results/<asset>/<row>/report HTML report of a result (row as in replay)
+
+A report is the one written with html_reports if it exists, otherwise the simulation is rerun like replay does.
+Rerunning loads the run's config, so it waits for no job and answers 503 while one is running.
+*/
+
+const runsPath = "/api/v1/runs"
+
+type runSummary struct {
+	Run        string     `json:"run"`
+	Started    time.Time  `json:"started"`
+	Finished   *time.Time `json:"finished,omitempty"`
+	Assets     []string   `json:"assets"`
+	Strategies []string   `json:"strategies"`
+	Jobs       int        `json:"jobs"`
+	Completed  int        `json:"completed"`
+	Failed     int        `json:"failed"`
+	GitCommit  string     `json:"git_commit"`
+	GitDirty   bool       `json:"git_dirty"`
+}
+
+type runResult struct {
+	results.Record
+	Row     int                `json:"row"` // 1-based position among the results of the asset
+	Metrics map[string]float64 `json:"metrics"`
+}
+
+func (s *apiServer) handleRuns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method [%s] not allowed", r.Method))
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(s.outputDir, "*.manifest.json"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	runs := []runSummary{}
+	for _, fileName := range files {
+		m, err := loadManifest(fileName)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		if m.Command != "sweep" {
+			continue
+		}
+		runs = append(runs, runSummary{m.Run, m.Started, m.Finished, m.Sweep.Assets, m.Sweep.Strategies, m.Jobs, m.Completed, m.Failed, m.GitCommit, m.GitDirty})
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Started.After(runs[j].Started)
+	})
+	writeJSON(w, http.StatusOK, runs)
+}
+
+/*
+<run>/results and <run>/results/<asset>/<row>/report
+*/
+func (s *apiServer) handleRun(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, runsPath), "/"), "/")
+	if r.Method != http.MethodGet || len(parts) < 2 || parts[1] != "results" || strings.ContainsAny(parts[0], `/\.`) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("Unknown path [%s %s]", r.Method, r.URL.Path))
+		return
+	}
+	manifestFile := filepath.Join(s.outputDir, parts[0]+".manifest.json")
+	m, err := loadManifest(manifestFile)
+	if os.IsNotExist(err) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("Unknown run [%s]", parts[0]))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	switch {
+	case len(parts) == 2:
+		runResults, err := getRunResults(m)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"metrics": results.Metrics, "results": runResults})
+	case len(parts) == 5 && parts[4] == "report":
+		row, err := strconv.Atoi(parts[3])
+		if err != nil || row < 1 {
+			writeError(w, http.StatusNotFound, fmt.Errorf("Invalid row [%s]", parts[3]))
+			return
+		}
+		s.handleReport(w, r, m, manifestFile, parts[2], row)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("Unknown path [%s %s]", r.Method, r.URL.Path))
+	}
+}
+
+/*
+Results of every asset of a run, assets without results are skipped
+*/
+func getRunResults(m *runManifest) ([]runResult, error) {
+	runResults := []runResult{}
+	for _, asset := range m.Sweep.Assets {
+		lines, err := getResultLines([]byte(m.Config), m.Run, asset)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i, line := range lines {
+			rec, err := results.ParseLine(line)
+			if err != nil {
+				return nil, fmt.Errorf("[%s] Row %d of run [%s]: %w", asset, i+1, m.Run, err)
+			}
+			rec.Asset = asset
+			metrics := map[string]float64{}
+			for _, name := range results.Metrics {
+				metrics[name], _ = rec.Metric(name, m.Sweep.InvestAmt)
+			}
+			runResults = append(runResults, runResult{rec, i + 1, metrics})
+		}
+	}
+	return runResults, nil
+}
+
+func (s *apiServer) handleReport(w http.ResponseWriter, r *http.Request, m *runManifest, manifestFile string, asset string, row int) {
+	lines, err := getResultLines([]byte(m.Config), m.Run, asset)
+	if err != nil || row > len(lines) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("[%s] Run [%s] has no row [%d]", asset, m.Run, row))
+		return
+	}
+	rec, err := results.ParseLine(lines[row-1])
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	cfg, err := ini.Load([]byte(m.Config))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	runLogDir := cfg.Section("Files").Key("log_dir").String()
+	reportFile := getLogFileName(runLogDir, m.Sweep.StartDate, m.Sweep.EndDate, asset, recordParams(rec), "html")
+	if _, err := os.Stat(reportFile); err == nil {
+		http.ServeFile(w, r, reportFile)
+		return
+	}
+	if !s.configMut.TryLock() {
+		w.Header().Set("Retry-After", "10")
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("A job is running, the report of run [%s] can be generated once it finishes", m.Run))
+		return
+	}
+	defer s.configMut.Unlock()
+	_, _, rec, dataFrame, err := loadRunResult(manifestFile, asset, row)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sim, err := newParamSetSimulation(asset, dataFrame, rec.DataFile, rec.DataHash, recordParams(rec), "")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	simulation.SetEventSink(&sim, simulation.NopSink{})
+	res := simulation.RunSimulation(r.Context(), &sim)
+	if res.Err != nil {
+		writeError(w, http.StatusInternalServerError, res.Err)
+		return
+	}
+	page, err := simulation.ReportHTML(&sim)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(page))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func getTestBody(t *testing.T, url string) string {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: expected status [%d] received [%d] %s", url, http.StatusOK, resp.StatusCode, body)
+	}
+	return string(body)
+}
+
+func TestRunReports(t *testing.T) {
+	confFile := writeTestConfig(t, "[Files]\nhtml_reports = true\n")
+	runName, err := sweep(context.Background(), confFile, "", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, server := startTestServer(t, confFile)
+	runs := []runSummary{}
+	getTestJSON(t, server.URL+runsPath, http.StatusOK, &runs)
+	if len(runs) != 1 || runs[0].Run != runName || runs[0].Completed != getNumSims(assets) {
+		t.Fatalf("Expected run [%s] with [%d] completed jobs Received %+v", runName, getNumSims(assets), runs)
+	}
+	body := struct {
+		Metrics []string    `json:"metrics"`
+		Results []runResult `json:"results"`
+	}{}
+	getTestJSON(t, fmt.Sprintf("%s%s/%s/results", server.URL, runsPath, runName), http.StatusOK, &body)
+	if len(body.Results) != getNumSims(assets) {
+		t.Fatalf("Expected [%d] results Received [%d]", getNumSims(assets), len(body.Results))
+	}
+	// The written report is served, without it the simulation of the row is rerun
+	reports := map[string]bool{}
+	err = filepath.WalkDir(logDir, func(fileName string, d fs.DirEntry, err error) error {
+		if err != nil || filepath.Ext(fileName) != ".html" {
+			return err
+		}
+		page, err := os.ReadFile(fileName)
+		reports[string(page)] = true
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != getNumSims(assets) {
+		t.Fatalf("Expected [%d] reports Received [%d]", getNumSims(assets), len(reports))
+	}
+	written := map[int]string{}
+	for _, r := range body.Results {
+		written[r.Row] = getTestBody(t, fmt.Sprintf("%s%s/%s/results/SYNTH/%d/report", server.URL, runsPath, runName, r.Row))
+		if !reports[written[r.Row]] {
+			t.Errorf("Row %d: served report is not one written by the sweep", r.Row)
+		}
+	}
+	if err := os.RemoveAll(logDir); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range body.Results {
+		rerun := getTestBody(t, fmt.Sprintf("%s%s/%s/results/SYNTH/%d/report", server.URL, runsPath, runName, r.Row))
+		if rerun != written[r.Row] {
+			t.Errorf("Row %d: report of the rerun differs from the written report", r.Row)
+		}
+	}
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"gopkg.in/ini.v1"
+
+	"Simulations_v5/dashboard"
+	"Simulations_v5/results"
+	"Simulations_v5/simulation"
+)
+
+/*
+REST API (serve [-addr host:port]), every body is JSON:
+
+	POST   /api/v1/jobs                          submit a spec, 202 with the queued job
+	GET    /api/v1/jobs                          every job, in submission order
+	GET    /api/v1/jobs/<id>                     status and progress of a job
+	DELETE /api/v1/jobs/<id>                     cancel a queued or running job
+	GET    /api/v1/jobs/<id>/results             results and failures saved so far
+	GET    /api/v1/jobs/<id>/results/<n>/events  events of the nth result (0-based)
+
+A spec has the fields of conf.ini, {"Simulation": {"assets": "BTC ETH"}, "Parameters": {"ema_values": [50, 100]}}.
+Its values override the server's conf.ini, lists may be arrays. A single simulation is a sweep with one value per parameter.
+Only the keys of specKeys may be set, directories and data sources stay the server's.
+Jobs are sweeps run one at a time in submission order since the config is process wide. Event logs of a job are
+written as JSON Lines under <log_dir>/api/<id>.
+
+The dashboard is served on / and browses the runs in output_dir with the runs API (see runs.go).
+*/
+
+const jobsPath = "/api/v1/jobs"
+
+const maxQueuedJobs = 1024
+
+var specKeys = map[string][]string{
+	"Simulation": {"assets", "start_date", "end_date", "invest_amt", "tax_rate", "fees", "timezone", "calendar", "session_start", "session_end", "holidays"},
+	"Parameters": {"strategies", "ema_values", "reinvest_percentages", "min_returns", "percent_drops", "balance_tripwires", "sell_condition"},
+	"Data":       {"interval", "quality_policy", "spike_threshold"},
+	"Files":      {"event_log", "html_reports"},
+	"Overfit":    {"blocks", "confidence"},
+}
+
+// Asset names are directories under data_dir, output_dir and log_dir
+var assetPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+type apiJob struct {
+	ID        int         `json:"id"`
+	Status    string      `json:"status"` // queued, running, done, failed or cancelled
+	Run       string      `json:"run,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Submitted time.Time   `json:"submitted"`
+	Started   *time.Time  `json:"started,omitempty"`
+	Finished  *time.Time  `json:"finished,omitempty"`
+	Progress  apiProgress `json:"progress"`
+	spec      map[string]map[string]string
+	store     *results.MemoryStore
+	cancel    context.CancelFunc
+	logDir    string // Event logs of the job, see getLogFileName
+	startDate string
+	endDate   string
+	eventLog  bool
+}
+
+/*
+Same numbers as the progress bar of a sweep
+*/
+type apiProgress struct {
+	Done    int     `json:"done"`
+	Total   int     `json:"total"`
+	Percent float64 `json:"percent"`
+	Elapsed string  `json:"elapsed"`
+	ETA     string  `json:"eta,omitempty"`
+}
+
+type apiServer struct {
+	confFile  string
+	outputDir string // Runs listed by the dashboard, see runs.go
+	mut       sync.Mutex
+	configMut sync.Mutex // Held while the process wide config is in use
+	jobs      []*apiJob  // Index is the job ID - 1
+	queue     chan *apiJob
+}
+
+func runServe(ctx context.Context, confFile string, args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", "127.0.0.1:8080", "address to listen on")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	s := &apiServer{confFile: confFile, outputDir: outputDir, queue: make(chan *apiJob, maxQueuedJobs)}
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: s.Handler()}
+	stopped := make(chan struct{})
+	go func() {
+		s.runQueue(ctx)
+		close(stopped)
+	}()
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	color.Green("Serving the API on http://%s%s and the dashboard on http://%s", listener.Addr(), jobsPath, listener.Addr())
+	err = server.Serve(listener)
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	// Let the running job save its partial results
+	<-stopped
+	return nil
+}
+
+func (s *apiServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(jobsPath, s.handleJobs)
+	mux.HandleFunc(jobsPath+"/", s.handleJob)
+	mux.HandleFunc(runsPath, s.handleRuns)
+	mux.HandleFunc(runsPath+"/", s.handleRun)
+	mux.Handle("/", dashboard.Handler())
+	return mux
+}
+
+/*
+Runs queued jobs one at a time until ctx is cancelled
+*/
+func (s *apiServer) runQueue(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-s.queue:
+			s.run(ctx, job)
+		}
+	}
+}
+
+func (s *apiServer) run(ctx context.Context, job *apiJob) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.mut.Lock()
+	if job.Status != "queued" {
+		s.mut.Unlock()
+		return
+	}
+	started := time.Now()
+	job.Status = "running"
+	job.Started = &started
+	job.cancel = cancel
+	s.mut.Unlock()
+	s.configMut.Lock()
+	runName, err := s.sweep(jobCtx, job)
+	s.configMut.Unlock()
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	finished := time.Now()
+	job.Finished = &finished
+	job.Run = runName
+	job.Progress = getProgress(started)
+	switch {
+	case err == nil:
+		job.Status = "done"
+	case errors.Is(err, context.Canceled):
+		job.Status = "cancelled"
+	default:
+		job.Status = "failed"
+		job.Error = err.Error()
+	}
+}
+
+func (s *apiServer) sweep(ctx context.Context, job *apiJob) (string, error) {
+	confFile, err := writeSpec(s.confFile, job.spec, job.ID)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(confFile)
+	outFileMut.Lock()
+	numSims, SimsComplete = 0, 0
+	outFileMut.Unlock()
+	if err := loadConfig(confFile); err != nil {
+		return "", err
+	}
+	s.mut.Lock()
+	job.logDir, job.startDate, job.endDate, job.eventLog = logDir, startDate, endDate, eventLog
+	s.mut.Unlock()
+	return sweep(ctx, confFile, "", false, job.store)
+}
+
+/*
+Writes baseConf with the values of spec to a temporary file, event logs go to <log_dir>/api/<id> as JSON Lines
+*/
+func writeSpec(baseConf string, spec map[string]map[string]string, id int) (string, error) {
+	cfg, err := ini.Load(baseConf)
+	if err != nil {
+		fmt.Printf("Failed to read %v\n", err)
+		return "", err
+	}
+	for section, keys := range spec {
+		for key, value := range keys {
+			cfg.Section(section).Key(key).SetValue(value)
+		}
+	}
+	files := cfg.Section("Files")
+	files.Key("log_dir").SetValue(fmt.Sprintf("%s/api/%d", files.Key("log_dir").String(), id))
+	files.Key("event_format").SetValue("json")
+	f, err := os.CreateTemp("", "job_*.ini")
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+	if err := cfg.SaveTo(f.Name()); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+/*
+Progress of the running sweep
+*/
+func getProgress(started time.Time) apiProgress {
+	outFileMut.Lock()
+	p := apiProgress{Done: SimsComplete, Total: numSims}
+	outFileMut.Unlock()
+	elapsed := time.Since(started)
+	p.Elapsed = elapsed.Round(time.Second).String()
+	if p.Total > 0 {
+		p.Percent = 100.0 * float64(p.Done) / float64(p.Total)
+	}
+	if p.Done > 0 && p.Done < p.Total {
+		p.ETA = (elapsed / time.Duration(p.Done) * time.Duration(p.Total-p.Done)).Round(time.Second).String()
+	}
+	return p
+}
+
+/*
+Copy of job with its current progress, must be called with s.mut held
+*/
+func (s *apiServer) status(job *apiJob) apiJob {
+	status := *job
+	if job.Status == "running" {
+		status.Progress = getProgress(*job.Started)
+	}
+	return status
+}
+
+func (s *apiServer) handleJobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.mut.Lock()
+		jobs := make([]apiJob, 0, len(s.jobs))
+		for _, job := range s.jobs {
+			jobs = append(jobs, s.status(job))
+		}
+		s.mut.Unlock()
+		writeJSON(w, http.StatusOK, jobs)
+	case http.MethodPost:
+		spec, err := parseSpec(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.mut.Lock()
+		defer s.mut.Unlock()
+		job := &apiJob{ID: len(s.jobs) + 1, Status: "queued", Submitted: time.Now(), spec: spec, store: results.NewMemoryStore()}
+		select {
+		case s.queue <- job:
+		default:
+			writeError(w, http.StatusServiceUnavailable, fmt.Errorf("Queue is full (%d jobs)", maxQueuedJobs))
+			return
+		}
+		s.jobs = append(s.jobs, job)
+		writeJSON(w, http.StatusAccepted, s.status(job))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method [%s] not allowed", r.Method))
+	}
+}
+
+/*
+<id>, <id>/results and <id>/results/<n>/events
+*/
+func (s *apiServer) handleJob(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, jobsPath), "/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	s.mut.Lock()
+	if err != nil || id < 1 || id > len(s.jobs) {
+		s.mut.Unlock()
+		writeError(w, http.StatusNotFound, fmt.Errorf("Unknown job [%s]", parts[0]))
+		return
+	}
+	job := s.jobs[id-1]
+	s.mut.Unlock()
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		s.mut.Lock()
+		status := s.status(job)
+		s.mut.Unlock()
+		writeJSON(w, http.StatusOK, status)
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		s.mut.Lock()
+		switch job.Status {
+		case "queued":
+			job.Status = "cancelled"
+		case "running":
+			job.cancel()
+		}
+		status := s.status(job)
+		s.mut.Unlock()
+		writeJSON(w, http.StatusOK, status)
+	case len(parts) == 2 && parts[1] == "results" && r.Method == http.MethodGet:
+		records, failures := job.store.Results()
+		writeJSON(w, http.StatusOK, map[string]interface{}{"results": records, "failures": failures})
+	case len(parts) == 4 && parts[1] == "results" && parts[3] == "events" && r.Method == http.MethodGet:
+		s.handleEvents(w, job, parts[2])
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("Unknown path [%s %s]", r.Method, r.URL.Path))
+	}
+}
+
+func (s *apiServer) handleEvents(w http.ResponseWriter, job *apiJob, index string) {
+	records, _ := job.store.Results()
+	n, err := strconv.Atoi(index)
+	if err != nil || n < 0 || n >= len(records) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("Job [%d] has [%d] results, received [%s]", job.ID, len(records), index))
+		return
+	}
+	s.mut.Lock()
+	enabled := job.eventLog
+	logFile := getLogFileName(job.logDir, job.startDate, job.endDate, records[n].Asset, recordParams(records[n]), "jsonl")
+	s.mut.Unlock()
+	if !enabled {
+		writeError(w, http.StatusNotFound, fmt.Errorf("Job [%d] was run with [event_log] none", job.ID))
+		return
+	}
+	events, err := readEvents(logFile)
+	if os.IsNotExist(err) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("Job [%d] has no event log for result [%d]: %w", job.ID, n, err))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+/*
+Parameters a result was configured with, result strings hold percentDrop negated as SetStratParams stores it
+*/
+func recordParams(rec results.Record) ParamSet {
+	return ParamSet{rec.Strategy, rec.EMA, rec.ReinvestPerc, rec.MinReturn, -1 * rec.PercentDrop, rec.BalanceTrip}
+}
+
+/*
+Events of a JSON Lines event log
+*/
+func readEvents(logFile string) ([]simulation.Event, error) {
+	events := []simulation.Event{}
+	f, err := os.Open(logFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		e := simulation.Event{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s: %w", logFile, err)
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
+
+/*
+Spec of a job, values are strings, numbers, booleans or arrays of them (joined with spaces like conf.ini lists)
+*/
+func parseSpec(r *http.Request) (map[string]map[string]string, error) {
+	raw := map[string]map[string]interface{}{}
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("Invalid spec: %w", err)
+	}
+	spec := map[string]map[string]string{}
+	for section, keys := range raw {
+		spec[section] = map[string]string{}
+		for key, value := range keys {
+			if !isSpecKey(section, key) {
+				return nil, fmt.Errorf("Invalid spec: [%s] %s cannot be set", section, key)
+			}
+			str, err := specValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid spec [%s] %s: %w", section, key, err)
+			}
+			spec[section][key] = str
+		}
+	}
+	if assets, ok := spec["Simulation"]["assets"]; ok {
+		for _, asset := range strings.Split(assets, " ") {
+			if !assetPattern.MatchString(asset) {
+				return nil, fmt.Errorf("Invalid spec: asset [%s]", asset)
+			}
+		}
+	}
+	return spec, nil
+}
+
+func isSpecKey(section string, key string) bool {
+	for _, k := range specKeys[section] {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func specValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []interface{}:
+		strs := []string{}
+		for _, item := range v {
+			str, err := specValue(item)
+			if err != nil {
+				return "", err
+			}
+			strs = append(strs, str)
+		}
+		return strings.Join(strs, " "), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", value)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
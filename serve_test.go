@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"Simulations_v5/results"
+	"Simulations_v5/simulation"
+)
+
+/*
+Starts serve on confFile, the queue stops with the test
+*/
+func startTestServer(t *testing.T, confFile string) (*apiServer, *httptest.Server) {
+	t.Helper()
+	s := &apiServer{confFile: confFile, outputDir: outputDir, queue: make(chan *apiJob, maxQueuedJobs)}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		s.runQueue(ctx)
+		close(stopped)
+	}()
+	server := httptest.NewServer(s.Handler())
+	t.Cleanup(func() {
+		server.Close()
+		cancel()
+		<-stopped
+	})
+	return s, server
+}
+
+func getTestJSON(t *testing.T, url string, status int, v interface{}) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != status {
+		t.Fatalf("GET %s: expected status [%d] received [%d]", url, status, resp.StatusCode)
+	}
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+/*
+Submits spec and waits for the job to finish
+*/
+func runTestJob(t *testing.T, server *httptest.Server, spec string) apiJob {
+	t.Helper()
+	resp, err := http.Post(server.URL+jobsPath, "application/json", strings.NewReader(spec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := apiJob{}
+	err = json.NewDecoder(resp.Body).Decode(&job)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected status [%d] received [%d]", http.StatusAccepted, resp.StatusCode)
+	}
+	deadline := time.Now().Add(time.Minute)
+	for job.Status == "queued" || job.Status == "running" {
+		if time.Now().After(deadline) {
+			t.Fatalf("Job [%d] did not finish", job.ID)
+		}
+		time.Sleep(50 * time.Millisecond)
+		getTestJSON(t, fmt.Sprintf("%s%s/%d", server.URL, jobsPath, job.ID), http.StatusOK, &job)
+	}
+	return job
+}
+
+func TestServeJobEvents(t *testing.T) {
+	confFile := writeTestConfig(t, "[Files]\nevent_log = file\n")
+	s, server := startTestServer(t, confFile)
+	job := runTestJob(t, server, `{"Parameters": {"min_returns": [0.05]}}`)
+	if job.Status != "done" {
+		t.Fatalf("Expected job status [done] received [%s]: %s", job.Status, job.Error)
+	}
+	body := struct {
+		Results  []results.Record  `json:"results"`
+		Failures []results.Failure `json:"failures"`
+	}{}
+	getTestJSON(t, fmt.Sprintf("%s%s/%d/results", server.URL, jobsPath, job.ID), http.StatusOK, &body)
+	if len(body.Results) != len(PercentDrop) || len(body.Failures) != 0 {
+		t.Fatalf("Expected [%d] results and no failures Received %d and %v", len(PercentDrop), len(body.Results), body.Failures)
+	}
+	for n := range body.Results {
+		events := []simulation.Event{}
+		getTestJSON(t, fmt.Sprintf("%s%s/%d/results/%d/events", server.URL, jobsPath, job.ID, n), http.StatusOK, &events)
+		if len(events) == 0 {
+			t.Errorf("Result %d (percent_drop %g) has no events", n, body.Results[n].PercentDrop)
+		}
+	}
+	// A missing log is not an empty one
+	if err := os.RemoveAll(s.jobs[0].logDir); err != nil {
+		t.Fatal(err)
+	}
+	getTestJSON(t, fmt.Sprintf("%s%s/%d/results/0/events", server.URL, jobsPath, job.ID), http.StatusNotFound, nil)
+}
+
+func TestServeJobsGetTheirOwnRun(t *testing.T) {
+	confFile := writeTestConfig(t, "")
+	_, server := startTestServer(t, confFile)
+	runs := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		job := runTestJob(t, server, `{"Parameters": {"min_returns": [0.05]}}`)
+		if job.Status != "done" {
+			t.Fatalf("Expected job status [done] received [%s]: %s", job.Status, job.Error)
+		}
+		if runs[job.Run] {
+			t.Fatalf("Job [%d] reused run [%s]", job.ID, job.Run)
+		}
+		runs[job.Run] = true
+		lines, err := getResultLines(confFile, job.Run, "SYNTH")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lines) != len(PercentDrop) {
+			t.Errorf("Run [%s]: expected [%d] results Received [%d]", job.Run, len(PercentDrop), len(lines))
+		}
+	}
+}
+
+func TestServeRejectsSpecs(t *testing.T) {
+	confFile := writeTestConfig(t, "")
+	_, server := startTestServer(t, confFile)
+	for _, spec := range []string{
+		`{"Files": {"output_dir": "/tmp"}}`,
+		`{"DataSources": {"SYNTH": "/etc/passwd"}}`,
+		`{"Simulation": {"assets": ["SYNTH", "../SYNTH"]}}`,
+		`{"Simulation": {"assets": "/SYNTH"}}`,
+	} {
+		resp, err := http.Post(server.URL+jobsPath, "application/json", strings.NewReader(spec))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected status [%d] received [%d]", spec, http.StatusBadRequest, resp.StatusCode)
+		}
+	}
+}
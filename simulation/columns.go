@@ -0,0 +1,73 @@
+package simulation
+
+import (
+	"fmt"
+	"strings"
+
+	"Simulations_v5/strategy"
+)
+
+/*
+Columns read by the simulation itself regardless of strategy
+*/
+var simColumns = []string{"Date", "Close"}
+
+type MissingColumnsError struct {
+	Asset         string
+	Strategy      string
+	SellCondition int
+	Missing       []string
+}
+
+func (e *MissingColumnsError) Error() string {
+	if e.Strategy == "" {
+		return fmt.Sprintf("[%s] Data is missing columns [%s]", e.Asset, strings.Join(e.Missing, " "))
+	}
+	return fmt.Sprintf("[%s] Strategy [%s] with sell condition [%d] requires missing columns [%s]", e.Asset, e.Strategy, e.SellCondition, strings.Join(e.Missing, " "))
+}
+
+/*
+Columns a simulation of strat/sellCondition/ema reads from the data file ("EMA" is replaced with EMA_<ema>)
+*/
+func RequiredColumns(strat string, sellCondition int, ema int) []string {
+	columns := append([]string{}, simColumns...)
+	for _, col := range strategy.RequiredColumns(strat, sellCondition) {
+		if col == "EMA" {
+			col = fmt.Sprintf("EMA_%d", ema)
+		}
+		if !containsString(columns, col) {
+			columns = append(columns, col)
+		}
+	}
+	return columns
+}
+
+/*
+Returns a *MissingColumnsError if names does not contain every column required by strat/sellCondition/ema.
+An empty strat only checks the columns read by the simulation itself.
+*/
+func CheckColumns(asset string, names []string, strat string, sellCondition int, ema int) error {
+	required := simColumns
+	if strat != "" {
+		required = RequiredColumns(strat, sellCondition, ema)
+	}
+	missing := []string{}
+	for _, col := range required {
+		if !containsString(names, col) {
+			missing = append(missing, col)
+		}
+	}
+	if len(missing) > 0 {
+		return &MissingColumnsError{asset, strat, sellCondition, missing}
+	}
+	return nil
+}
+
+func containsString(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCheckColumns(t *testing.T) {
+	complete := []string{"Date", "Open", "High", "Low", "Close", "Volume", "EMA_50", "MACD", "SIGNAL", "dP", "SAR", "CHAI"}
+	without := func(columns ...string) []string {
+		names := []string{}
+		for _, name := range complete {
+			if !containsString(columns, name) {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	for _, test := range []struct {
+		name          string
+		names         []string
+		strat         string
+		sellCondition int
+		ema           int
+		missing       []string
+	}{
+		{"complete", complete, "MACD-PSAR", 4, 50, nil},
+		{"no SAR", without("SAR"), "PSAR", 1, 50, []string{"SAR"}},
+		{"no SAR or CHAI", without("SAR", "CHAI"), "MACD-CHAI", 1, 50, []string{"CHAI"}},
+		{"sell condition", without("dP"), "MACD", 6, 50, []string{"dP"}},
+		{"other EMA", complete, "MACD", 2, 20, []string{"EMA_20"}},
+		{"several", without("MACD", "SIGNAL", "SAR"), "MACD-PSAR", 1, 50, []string{"MACD", "SIGNAL", "SAR"}},
+		{"no strategy", without("Close"), "", 0, 0, []string{"Close"}},
+	} {
+		err := CheckColumns("TEST", test.names, test.strat, test.sellCondition, test.ema)
+		if test.missing == nil {
+			if err != nil {
+				t.Errorf("%s: expected no error Received %v", test.name, err)
+			}
+			continue
+		}
+		var missingErr *MissingColumnsError
+		if !errors.As(err, &missingErr) {
+			t.Errorf("%s: expected a MissingColumnsError Received %v", test.name, err)
+			continue
+		}
+		if fmt.Sprint(missingErr.Missing) != fmt.Sprint(test.missing) || !strings.Contains(err.Error(), strings.Join(test.missing, " ")) {
+			t.Errorf("%s: expected missing columns %v Received %v (%v)", test.name, test.missing, missingErr.Missing, err)
+		}
+	}
+}
+
+func TestSetStratParamsMissingColumns(t *testing.T) {
+	sim, err := NewSimulation("TEST", 1000, 0.2, 0.001, "test.csv", newTestData(50).Drop("SAR"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SetStratParams(&sim, "PSAR", 1, 3, 0.5, 0.01, 0.05, 0.5)
+	var missingErr *MissingColumnsError
+	if !errors.As(err, &missingErr) || fmt.Sprint(missingErr.Missing) != "[SAR]" {
+		t.Errorf("Expected SAR to be missing Received %v", err)
+	}
+	if err := SetStratParams(&sim, "MACD", 1, 3, 0.5, 0.01, 0.05, 0.5); err != nil {
+		t.Errorf("Expected MACD to run without SAR Received %v", err)
+	}
+}
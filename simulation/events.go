@@ -0,0 +1,124 @@
+package simulation
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"Simulations_v5/calendar"
+	"Simulations_v5/strategy"
+)
+
+/*
+Event logged every time the simulation BUYs, SELLs, BALances or opens reserves (OR)
+*/
+type Event struct {
+	Kind       string               `json:"kind"`             // BUY, SELL, BAL or OR
+	AssetName  string               `json:"asset_name"`       // Name of asset being simulated
+	Timestamp  int                  `json:"timestamp"`        // Date of the row the event happened on
+	Time       string               `json:"time"`             // Timestamp in the simulation's timezone
+	Index      int                  `json:"index"`            // Index of the row the event happened on
+	Price      float64              `json:"price"`            // Close price of the row (in USD)
+	Amount     float64              `json:"amount,omitempty"` // Amount of the lot sold (SELL only, in asset)
+	Capital    float64              `json:"capital"`          // Capital after the event (in USD)
+	Asset      float64              `json:"asset"`            // Asset held after the event (in asset)
+	Reserves   float64              `json:"reserves"`         // Reserves after the event (in USD)
+	Revenue    float64              `json:"revenue"`          // Revenue accrued after the event (in USD)
+	Tax        float64              `json:"tax"`              // Taxes accrued after the event (in USD)
+	Fees       float64              `json:"fees"`             // Fees accrued after the event (in USD)
+	Lots       []Lot                `json:"lots"`             // Purchase history after the event
+	Indicators []strategy.Indicator `json:"indicators"`       // Indicator values used by the strategy
+}
+
+type Lot struct {
+	Amount float64 `json:"amount"`
+	Price  float64 `json:"price"`
+	Index  int     `json:"index"`
+}
+
+/*
+Formats an event as a single line (including the trailing newline)
+*/
+type EventFormatter func(e Event) (string, error)
+
+var eventFormatters = map[string]EventFormatter{
+	"json": FormatEventJSON,
+	"text": FormatEventText,
+}
+
+/*
+JSON Lines, NaN indicators are written as null (see strategy.Indicator) and any other value JSON can't encode is an error
+*/
+func FormatEventJSON(e Event) (string, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", fmt.Errorf("[%s] %s event at index %d: %w", e.AssetName, e.Kind, e.Index, err)
+	}
+	return string(data) + "\n", nil
+}
+
+/*
+Original MPBR log format:
+
+	BUY,Timestamp 123,Index 4,Price ...,PurchaseHist [(Amount:.. )],Strat: [EMA: ..]
+	SELL,Amount ..,Timestamp 123,...
+*/
+func FormatEventText(e Event) (string, error) {
+	str := e.Kind + ","
+	if e.Kind == "SELL" {
+		str += fmt.Sprintf("Amount %g,", e.Amount)
+	}
+	str += fmt.Sprintf("Timestamp %d,", e.Timestamp)
+	str += fmt.Sprintf("Index %d,", e.Index)
+	str += fmt.Sprintf("Price %g,", roundFloat(e.Price, 2))
+	str += fmt.Sprintf("Capital %g,", roundFloat(e.Capital, 2))
+	str += fmt.Sprintf("Asset %g,", e.Asset)
+	str += fmt.Sprintf("Reserves %g,", roundFloat(e.Reserves, 2))
+	str += fmt.Sprintf("Revenue %g,", roundFloat(e.Revenue, 2))
+	str += fmt.Sprintf("Tax %g,", roundFloat(e.Tax, 2))
+	str += fmt.Sprintf("Fees %g,", roundFloat(e.Fees, 2))
+	str += fmt.Sprintf("PurchaseHist [")
+	for _, lot := range e.Lots {
+		str += fmt.Sprintf("(Amount:%g Price:%g Index:%d),", lot.Amount, lot.Price, lot.Index)
+	}
+	str += fmt.Sprintf("],")
+	str += strategy.FormatIndicators(e.Indicators)
+	return str + "\n", nil
+}
+
+func IsValidEventFormat(format string) bool {
+	_, ok := eventFormatters[format]
+	return ok
+}
+
+func GetEventFormatter(format string) (EventFormatter, error) {
+	formatter, ok := eventFormatters[format]
+	if !ok {
+		return nil, fmt.Errorf("Invalid event format [%s]", format)
+	}
+	return formatter, nil
+}
+
+func newEvent(kind string, s *Simulation) Event {
+	row := s.dFrame.data.Subset(s.dFrame.index)
+	timestamp, _ := row.Select("Date").Elem(0, 0).Int()
+	lots := make([]Lot, 0, len(s.purchaseHistory))
+	for _, p := range s.purchaseHistory {
+		lots = append(lots, Lot{p.amount, p.price, p.index})
+	}
+	return Event{
+		Kind:       kind,
+		AssetName:  s.assetName,
+		Timestamp:  timestamp,
+		Time:       calendar.FormatTime(int64(timestamp), s.location),
+		Index:      s.dFrame.index,
+		Price:      row.Select("Close").Elem(0, 0).Float(),
+		Capital:    s.capital,
+		Asset:      s.asset,
+		Reserves:   s.reserves,
+		Revenue:    s.revenue,
+		Tax:        s.tax,
+		Fees:       s.fees,
+		Lots:       lots,
+		Indicators: strategy.GetIndicators(s.strat, &row),
+	}
+}
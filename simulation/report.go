@@ -0,0 +1,142 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"Simulations_v5/calendar"
+	"Simulations_v5/report"
+	"Simulations_v5/stats"
+)
+
+/*
+Indicator columns plotted when present in the data, grouped by panel
+*/
+var priceIndicators = []string{"EMA", "SAR"}
+var macdIndicators = []string{"MACD", "SIGNAL"}
+
+var lineColors = map[string]string{
+	"Close":  "#1f77b4",
+	"EMA":    "#ff7f0e",
+	"SAR":    "#9467bd",
+	"MACD":   "#2ca02c",
+	"SIGNAL": "#d62728",
+}
+
+/*
+Writes a self-contained HTML report for a completed simulation (see ReportHTML)
+*/
+func WriteReport(s *Simulation, fileName string) error {
+	page, err := ReportHTML(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, []byte(page), 0644)
+}
+
+/*
+Self-contained HTML report of a completed simulation:
+
+	price panel with indicators and BUY/SELL/BAL/OR markers
+	MACD/SIGNAL panel (if present in the data)
+	equity curve (capital + reserves + asset + revenue)
+	drawdown of the equity curve
+*/
+func ReportHTML(s *Simulation) (string, error) {
+	times, err := getTimes(s)
+	if err != nil {
+		return "", err
+	}
+	closes := s.dFrame.data.Col("Close").Float()
+	if len(closes) == 0 {
+		return "", errors.New("No data to report")
+	}
+	price := report.Panel{Title: "Price", Height: 320, Lines: []report.Line{{Name: "Close", Color: lineColors["Close"], Values: closes}}}
+	for _, col := range priceIndicators {
+		if hasColumn(s, col) {
+			price.Lines = append(price.Lines, report.Line{Name: col, Color: lineColors[col], Values: s.dFrame.data.Col(col).Float()})
+		}
+	}
+	price.Markers = append(price.Markers, getMarkers(s.buys, "BUY", "#2ca02c", closes, times, s.location)...)
+	price.Markers = append(price.Markers, getMarkers(s.sells, "SELL", "#d62728", closes, times, s.location)...)
+	price.Markers = append(price.Markers, getMarkers(s.balances, "BAL", "#1f77b4", closes, times, s.location)...)
+	price.Markers = append(price.Markers, getMarkers(s.openReserves, "OR", "#ff7f0e", closes, times, s.location)...)
+	panels := []report.Panel{price}
+	macd := report.Panel{Title: "MACD", Height: 140}
+	for _, col := range macdIndicators {
+		if hasColumn(s, col) {
+			macd.Lines = append(macd.Lines, report.Line{Name: col, Color: lineColors[col], Values: s.dFrame.data.Col(col).Float()})
+		}
+	}
+	if len(macd.Lines) > 0 {
+		panels = append(panels, macd)
+	}
+	drawdowns := stats.Drawdowns(s.equity)
+	for i := range drawdowns {
+		drawdowns[i] = -100 * drawdowns[i]
+	}
+	panels = append(panels, report.Panel{Title: "Equity (USD)", Height: 180, Lines: []report.Line{{Name: "Equity", Color: "#17becf", Values: s.equity}}})
+	panels = append(panels, report.Panel{Title: "Drawdown (%)", Height: 120, Lines: []report.Line{{Name: "Drawdown", Color: "#d62728", Values: drawdowns}}})
+	finalPrice := closes[len(closes)-1]
+	summary := [][]string{
+		{"Strategy", s.strat},
+		{"EMA", fmt.Sprintf("%d", s.stratEMA)},
+		{"Sell Condition", fmt.Sprintf("%d", s.sellCondition)},
+		{"Reinvest Percentage", fmt.Sprintf("%g", s.reinvestPercentage)},
+		{"Min Return", fmt.Sprintf("%g", s.minReturn)},
+		{"Percent Drop", fmt.Sprintf("%g", -1*s.percentDrop)},
+		{"Balance Tripwire", fmt.Sprintf("%g", s.balanceTrip)},
+		{"Initial Investment", fmt.Sprintf("%g", s.initialInvestment)},
+		{"Final Value", fmt.Sprintf("%g", roundFloat(s.capital+s.reserves+(s.asset*finalPrice), 2))},
+		{"Revenue", fmt.Sprintf("%g", roundFloat(s.revenue, 2))},
+		{"Buy & Hold", fmt.Sprintf("%g", getBuyHold(s))},
+		{"Tax", fmt.Sprintf("%g", roundFloat(s.tax, 2))},
+		{"Fees", fmt.Sprintf("%g", roundFloat(s.fees, 2))},
+		{"Transactions", fmt.Sprintf("%d", s.numTransactions)},
+		{"Max Drawdown", fmt.Sprintf("%.2f%%", 100*stats.MaxDrawdown(s.equity))},
+		{"Start", calendar.FormatTime(times[0], s.location)},
+		{"End", calendar.FormatTime(times[len(times)-1], s.location)},
+		{"Data File", s.dataFile},
+	}
+	body := report.Table([]string{"", ""}, summary)
+	body += "<p>Markers: BUY (green), SELL (red), BAL (blue), OR (orange)</p>\n"
+	body += report.Chart(times, s.location, panels)
+	title := fmt.Sprintf("%s %s EMA-%d MPBR-%g_%g_%g_%g", s.assetName, s.strat, s.stratEMA, s.minReturn, -1*s.percentDrop, s.balanceTrip, s.reinvestPercentage)
+	return report.Page(title, body), nil
+}
+
+func getTimes(s *Simulation) ([]int64, error) {
+	dates, err := s.dFrame.data.Col("Date").Int()
+	if err != nil {
+		return nil, err
+	}
+	times := make([]int64, len(dates))
+	for i, d := range dates {
+		times[i] = int64(d)
+	}
+	return times, nil
+}
+
+func getMarkers(indices []int, kind string, color string, closes []float64, times []int64, loc *time.Location) []report.Marker {
+	markers := make([]report.Marker, 0, len(indices))
+	for _, i := range indices {
+		label := kind
+		if i >= 0 && i < len(closes) && !math.IsNaN(closes[i]) {
+			label = fmt.Sprintf("%s %s Index %d Price %g", kind, calendar.FormatTime(times[i], loc), i, roundFloat(closes[i], 2))
+		}
+		markers = append(markers, report.Marker{Index: i, Label: label, Color: color})
+	}
+	return markers
+}
+
+func hasColumn(s *Simulation, col string) bool {
+	for _, name := range s.dFrame.data.Names() {
+		if name == col {
+			return true
+		}
+	}
+	return false
+}
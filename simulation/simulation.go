@@ -1,11 +1,12 @@
 package simulation
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
-	"os"
 	"sort"
+	"time"
 
 	"github.com/go-gota/gota/dataframe"
 
@@ -13,9 +14,29 @@ import (
 )
 
 type Result struct {
-	AssetName    string // Name of asset being simulated
-	ResultString string // string containing relavent comma separated results
-	Err          error  // Error if Error occurs
+	AssetName    string    // Name of asset being simulated
+	ResultString string    // string containing relavent comma separated results
+	FinalValue   float64   // Total value of capital, reserves and asset at the end of the simulation (in USD)
+	Equity       []float64 // Total value of capital, reserves, asset and revenue at every index (in USD)
+	Trades       []Trade   // Every lot sold during the simulation
+	OpenTrades   []Trade   // Lots still held at the end, marked to the last Close as if sold
+	Err          error     // Error if Error occurs
+}
+
+type Trade struct {
+	BuyIndex  int     // Index the lot was bought at
+	SellIndex int     // Index the lot was sold at
+	BuyPrice  float64 // Price the lot was bought at
+	SellPrice float64 // Price the lot was sold at
+	Amount    float64 // Amount of asset in the lot (in asset)
+	Profit    float64 // Gain after fees and tax (in USD)
+}
+
+/*
+Profit as a fraction of the lot's cost
+*/
+func (t Trade) Return() float64 {
+	return t.Profit / (t.Amount * t.BuyPrice)
 }
 
 type df struct {
@@ -25,34 +46,40 @@ type df struct {
 }
 
 type Simulation struct {
-	initialInvestment  float64    // Initial Investment amount in USD
-	assetName          string     // Name of asset being simulated
-	dataFile           string     // data file used for the simulation
-	logFile            string     // log file used for the simulation
-	feePercentage      float64    // Percentage paid in fees for trading
-	taxRate            float64    // Percentage paid in TAX for trading
-	capital            float64    // Amount of capital ready to be traded for asset (in USD)
-	reserves           float64    // Amount of capital in reserves for future capital if price drops (in USD)
-	lastBuyPrice       float64    // Last Price the asset was bought at
-	revenue            float64    // Amount of revenue accrued (in USD)
-	tax                float64    // Amount of taxes accrued (in USD)
-	fees               float64    // Amount of fees accrued (in USD)
-	asset              float64    // Amount of asset in posession ready to be sold (in asset)
-	numTransactions    int        // Number of transactions completed for the simulation
-	strat              string     // MACD, PSAR, or other...(program it later)
-	sellCondition      int        // Integer representing sell parameters used to sell (1, 2, .. 5) See strategy.go
-	stratEMA           int        // EMA used for strategy
-	reinvestPercentage float64    // Percentage of profit that is reallocated to capital for further investments
-	minReturn          float64    // Min return for sell "Profit Margin"
-	percentDrop        float64    // Percentage of negative return where reserves are used to supplement capital
-	balanceTrip        float64    // Tripwire for capital and reserves to be balanced -> capital, reserves = (capital + reserves) / 2
-	minReserves        float64    // Minimum held in reserves (point where percentDrop is no longer in effect)
-	buys               []int      // List containing indices of times the simulation BUYS the asset
-	sells              []int      // List containing indices of times the simulation SELLS the asset
-	balances           []int      // List containing indices of times the simulation BALANCES capital and reserves
-	openReserves       []int      // List containing indices of times the simulation OPENS RESERVES for more capital
-	purchaseHistory    []purchase // List containing history of purchases bought
-	dFrame             df         // Custom dataframe used to iterate through data for simulation
+	initialInvestment  float64        // Initial Investment amount in USD
+	assetName          string         // Name of asset being simulated
+	dataFile           string         // data file used for the simulation
+	dataHash           string         // sha256 of the data file (empty if unknown)
+	location           *time.Location // Timezone of human readable timestamps in events and reports
+	logFile            string         // log file used for the simulation
+	feePercentage      float64        // Percentage paid in fees for trading
+	taxRate            float64        // Percentage paid in TAX for trading
+	capital            float64        // Amount of capital ready to be traded for asset (in USD)
+	reserves           float64        // Amount of capital in reserves for future capital if price drops (in USD)
+	lastBuyPrice       float64        // Last Price the asset was bought at
+	revenue            float64        // Amount of revenue accrued (in USD)
+	tax                float64        // Amount of taxes accrued (in USD)
+	fees               float64        // Amount of fees accrued (in USD)
+	asset              float64        // Amount of asset in posession ready to be sold (in asset)
+	numTransactions    int            // Number of transactions completed for the simulation
+	strat              string         // MACD, PSAR, or other...(program it later)
+	sellCondition      int            // Integer representing sell parameters used to sell (1, 2, .. 5) See strategy.go
+	stratEMA           int            // EMA used for strategy
+	reinvestPercentage float64        // Percentage of profit that is reallocated to capital for further investments
+	minReturn          float64        // Min return for sell "Profit Margin"
+	percentDrop        float64        // Percentage of negative return where reserves are used to supplement capital
+	balanceTrip        float64        // Tripwire for capital and reserves to be balanced -> capital, reserves = (capital + reserves) / 2
+	minReserves        float64        // Minimum held in reserves (point where percentDrop is no longer in effect)
+	buys               []int          // List containing indices of times the simulation BUYS the asset
+	sells              []int          // List containing indices of times the simulation SELLS the asset
+	balances           []int          // List containing indices of times the simulation BALANCES capital and reserves
+	openReserves       []int          // List containing indices of times the simulation OPENS RESERVES for more capital
+	purchaseHistory    []purchase     // List containing history of purchases bought
+	equity             []float64      // List containing total value (including revenue) at every index
+	trades             []Trade        // List containing every lot sold
+	events             EventSink      // Destination of BUY/SELL/BAL/OR events
+	err                error          // First error writing events
+	dFrame             df             // Custom dataframe used to iterate through data for simulation
 }
 
 type purchase struct {
@@ -75,22 +102,31 @@ func (p purchaseHistory) Swap(i, j int) {
 	p[i], p[j] = p[j], p[i]
 }
 
-func logEvent(event string, s *Simulation) error {
-	f, err := os.OpenFile(s.logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return err
-	}
-	if _, err := f.Write([]byte(event)); err != nil {
-		return err
-	}
-	if err := f.Close(); err != nil {
-		return err
+/*
+Writes event to the simulation's sink, the first error is kept and returned in Result.Err
+*/
+func logEvent(event Event, s *Simulation) {
+	if err := s.events.Write(event); err != nil && s.err == nil {
+		s.err = err
 	}
-	return nil
 }
 
-func RunSimulation(s *Simulation) Result {
+/*
+Runs the simulation over every row of data, stopping early (without a ResultString) once ctx is cancelled.
+Errors (including panics from missing or malformed data) are returned in Result.Err.
+*/
+func RunSimulation(ctx context.Context, s *Simulation) (r Result) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			s.events.Close()
+			r = Result{AssetName: s.assetName, Err: fmt.Errorf("[%s] Simulation failed at index %d: %v", s.assetName, s.dFrame.index, rec)}
+		}
+	}()
 	for {
+		if err := ctx.Err(); err != nil {
+			s.events.Close()
+			return Result{AssetName: s.assetName, Equity: s.equity, Trades: s.trades, Err: fmt.Errorf("[%s] Simulation cancelled at index %d: %w", s.assetName, s.dFrame.index, err)}
+		}
 		buy, sell, openRes := calcPositions(s)
 		if sell {
 			sellAsset(s)
@@ -102,14 +138,35 @@ func RunSimulation(s *Simulation) Result {
 		} else if openRes {
 			openReserves(s)
 		}
+		s.equity = append(s.equity, getSimTotalValue(s)+roundFloat(s.revenue, 2))
 		s.dFrame.index += 1
 		if s.dFrame.index >= s.dFrame.nRows {
 			s.dFrame.index -= 1
 			break
 		}
 	}
+	if err := s.events.Close(); err != nil && s.err == nil {
+		s.err = err
+	}
 	price := s.dFrame.data.Subset(s.dFrame.index).Select("Close").Elem(0, 0).Float()
-	return Result{s.assetName, getResultString(s, price), nil}
+	return Result{s.assetName, getResultString(s, price), getSimTotalValue(s), s.equity, s.trades, getOpenTrades(s, price), s.err}
+}
+
+/*
+Lots in the purchase history valued as if sold at price (after fees and tax)
+*/
+func getOpenTrades(s *Simulation, price float64) []Trade {
+	trades := make([]Trade, 0, len(s.purchaseHistory))
+	for _, p := range s.purchaseHistory {
+		usdValueSold := p.amount * price
+		gain := usdValueSold - (p.amount * p.price)
+		reward := gain - usdValueSold*s.feePercentage
+		if gain > 0.0 {
+			reward -= gain * s.taxRate
+		}
+		trades = append(trades, Trade{p.index, s.dFrame.index, p.price, price, p.amount, reward})
+	}
+	return trades
 }
 
 func calcPositions(s *Simulation) (bool, bool, bool) {
@@ -166,8 +223,9 @@ func sellAsset(s *Simulation) {
 			s.tax += tax
 			s.fees += fee
 			s.purchaseHistory = s.purchaseHistory[1:]
-			snapshot := getSnapshot(s)
-			event := fmt.Sprintf("SELL,Amount %g,%s\n", p.amount, snapshot)
+			s.trades = append(s.trades, Trade{p.index, s.dFrame.index, p.price, price, p.amount, reward})
+			event := newEvent("SELL", s)
+			event.Amount = p.amount
 			logEvent(event, s)
 		} else {
 			break
@@ -182,40 +240,14 @@ func balanceFunds(s *Simulation) {
 	s.capital = total / 2
 	s.reserves = total / 2
 	s.balances = append(s.balances, s.dFrame.index)
-	snapshot := getSnapshot(s)
-	event := fmt.Sprintf("BAL,%s\n", snapshot)
-	logEvent(event, s)
+	logEvent(newEvent("BAL", s), s)
 }
 
 func openReserves(s *Simulation) {
 	s.capital = s.reserves / 2
 	s.reserves = s.reserves / 2
 	s.openReserves = append(s.openReserves, s.dFrame.index)
-	snapshot := getSnapshot(s)
-	event := fmt.Sprintf("OR,%s\n", snapshot)
-	logEvent(event, s)
-}
-
-func getSnapshot(s *Simulation) string {
-	timestamp, _ := s.dFrame.data.Subset(s.dFrame.index).Select("Date").Elem(0, 0).Int()
-	price := s.dFrame.data.Subset(s.dFrame.index).Select("Close").Elem(0, 0).Float()
-	snap := fmt.Sprintf("Timestamp %d,", timestamp)
-	snap += fmt.Sprintf("Index %d,", s.dFrame.index)
-	snap += fmt.Sprintf("Price %g,", roundFloat(price, 2))
-	snap += fmt.Sprintf("Capital %g,", roundFloat(s.capital, 2))
-	snap += fmt.Sprintf("Asset %g,", s.asset)
-	snap += fmt.Sprintf("Reserves %g,", roundFloat(s.reserves, 2))
-	snap += fmt.Sprintf("Revenue %g,", roundFloat(s.revenue, 2))
-	snap += fmt.Sprintf("Tax %g,", roundFloat(s.tax, 2))
-	snap += fmt.Sprintf("Fees %g,", roundFloat(s.fees, 2))
-	snap += fmt.Sprintf("PurchaseHist [")
-	for _, purchase := range s.purchaseHistory {
-		snap += fmt.Sprintf("(Amount:%g Price:%g Index:%d),", purchase.amount, purchase.price, purchase.index)
-	}
-	snap += fmt.Sprintf("],")
-	row := s.dFrame.data.Subset(s.dFrame.index)
-	snap += strategy.GetStratString(s.strat, &row)
-	return snap
+	logEvent(newEvent("OR", s), s)
 }
 
 /*
@@ -238,9 +270,7 @@ func buyAsset(s *Simulation) {
 	s.lastBuyPrice = price
 	s.numTransactions += 1
 	s.buys = append(s.buys, s.dFrame.index)
-	snapshot := getSnapshot(s)
-	event := fmt.Sprintf("BUY,%s\n", snapshot)
-	logEvent(event, s)
+	logEvent(newEvent("BUY", s), s)
 }
 
 func getBuyHold(s *Simulation) float64 {
@@ -311,8 +341,11 @@ func getResultString(s *Simulation, price float64) string {
 	for _, openR := range s.openReserves {
 		str += fmt.Sprintf("%d ", openR)
 	}
-	str += fmt.Sprintf("],%s\n", s.dataFile)
-	return str
+	str += fmt.Sprintf("],%s", s.dataFile)
+	if s.dataHash != "" {
+		str += fmt.Sprintf(",%s", s.dataHash)
+	}
+	return str + "\n"
 }
 
 func isBuy(strat string, row dataframe.DataFrame) bool {
@@ -332,6 +365,8 @@ func NewSimulation(asset string, investAMT float64, taxRate float64, feePercenta
 		initialInvestment:  investAMT,
 		assetName:          asset,
 		dataFile:           dataFile,
+		dataHash:           "",
+		location:           time.UTC,
 		logFile:            logFile,
 		feePercentage:      feePercentage,
 		taxRate:            taxRate,
@@ -355,6 +390,10 @@ func NewSimulation(asset string, investAMT float64, taxRate float64, feePercenta
 		balances:           []int{},
 		openReserves:       []int{},
 		purchaseHistory:    []purchase{},
+		equity:             make([]float64, 0, dFrame.nRows),
+		trades:             []Trade{},
+		events:             NewFileSink(logFile, FormatEventJSON),
+		err:                nil,
 		dFrame:             dFrame,
 	}
 	return s, nil
@@ -369,6 +408,13 @@ func SetStratParams(s *Simulation, strat string, sellCondition int, ema int, rei
 	} else {
 		return errors.New("Invalid strategy")
 	}
+	if !strategy.IsValidSellCondition(sellCondition) {
+		return fmt.Errorf("Invalid sell condition [%d]", sellCondition)
+	}
+	err := CheckColumns(s.assetName, s.dFrame.data.Names(), strat, sellCondition, ema)
+	if err != nil {
+		return err
+	}
 	s.stratEMA = ema
 	s.sellCondition = sellCondition
 	emaStr := fmt.Sprintf("EMA_%d", ema)
@@ -380,3 +426,17 @@ func SetStratParams(s *Simulation, strat string, sellCondition int, ema int, rei
 	s.dFrame.index = 0
 	return nil
 }
+
+/*
+Sets the content hash of the data file, appended to the result string
+*/
+func SetDataHash(s *Simulation, hash string) {
+	s.dataHash = hash
+}
+
+/*
+Sets the timezone of human readable timestamps in events and reports
+*/
+func SetLocation(s *Simulation, loc *time.Location) {
+	s.location = loc
+}
@@ -0,0 +1,185 @@
+package simulation
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-gota/gota/dataframe"
+
+	"Simulations_v5/data"
+	"Simulations_v5/strategy"
+)
+
+/*
+Hourly bars of a rising sine wave, enough swings for MACD to buy and sell
+*/
+func newTestData(n int) dataframe.DataFrame {
+	bars := make([]data.Bar, n)
+	for i := range bars {
+		price := 100 + 10*math.Sin(float64(i)/10) + 0.05*float64(i)
+		bars[i] = data.Bar{Date: 1672531200 + int64(i)*3600, Open: price, High: price + 1, Low: price - 1, Close: price, Volume: 1}
+	}
+	return data.BarsToDataFrame(bars, []int{3}, true, true)
+}
+
+func newTestSimulation(t *testing.T, sink EventSink) Simulation {
+	t.Helper()
+	sim, err := NewSimulation("TEST", 1000, 0.2, 0.001, "test.csv", newTestData(500), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetStratParams(&sim, "MACD", 1, 3, 0.5, 0.01, 0.05, 0.5); err != nil {
+		t.Fatal(err)
+	}
+	SetEventSink(&sim, sink)
+	return sim
+}
+
+func TestRunSimulationEvents(t *testing.T) {
+	sink := &MemorySink{}
+	sim := newTestSimulation(t, sink)
+	r := RunSimulation(context.Background(), &sim)
+	if r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	if len(r.Equity) != 500 {
+		t.Errorf("Expected an equity value per bar [500] Received [%d]", len(r.Equity))
+	}
+	// BUY, SELL, BAL and OR indices of the result string, one event each (one SELL per lot)
+	fields := strings.Split(strings.TrimSpace(r.ResultString), ",")
+	indices := map[string]string{}
+	for i, kind := range []string{"BUY", "SELL", "BAL", "OR"} {
+		indices[kind] = fmt.Sprint(strings.Fields(strings.Trim(fields[14+i], "[]")))
+	}
+	events := map[string][]string{"BUY": {}, "SELL": {}, "BAL": {}, "OR": {}}
+	sold := map[int]bool{}
+	for _, e := range sink.Events {
+		if e.Kind == "SELL" {
+			if !sold[e.Index] {
+				events["SELL"] = append(events["SELL"], fmt.Sprint(e.Index))
+			}
+			sold[e.Index] = true
+			continue
+		}
+		events[e.Kind] = append(events[e.Kind], fmt.Sprint(e.Index))
+	}
+	if len(sink.Events) == 0 || len(events["BUY"]) == 0 || len(events["SELL"]) == 0 {
+		t.Fatalf("Expected BUY and SELL events Received %d events", len(sink.Events))
+	}
+	for kind, expected := range indices {
+		if received := fmt.Sprint(events[kind]); received != expected {
+			t.Errorf("%s: expected indices %s Received %s", kind, expected, received)
+		}
+	}
+	sells := 0
+	for _, e := range sink.Events {
+		if e.Kind == "SELL" {
+			sells += 1
+		}
+	}
+	if sells != len(r.Trades) {
+		t.Errorf("Expected a SELL event per trade [%d] Received [%d]", len(r.Trades), sells)
+	}
+}
+
+func TestFileSinkMatchesMemorySink(t *testing.T) {
+	memory := &MemorySink{}
+	sim := newTestSimulation(t, memory)
+	if r := RunSimulation(context.Background(), &sim); r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	fileName := filepath.Join(t.TempDir(), "events.jsonl")
+	// An earlier attempt of the job left a log behind
+	if err := os.WriteFile(fileName, []byte("{\"kind\":\"BUY\"}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sim = newTestSimulation(t, NewFileSink(fileName, FormatEventJSON))
+	if r := RunSimulation(context.Background(), &sim); r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	lines := 0
+	for ; scanner.Scan(); lines++ {
+		if lines >= len(memory.Events) {
+			continue
+		}
+		expected, err := json.Marshal(memory.Events[lines])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scanner.Text() != string(expected) {
+			t.Errorf("Line %d: expected %s Received %s", lines+1, expected, scanner.Text())
+		}
+	}
+	if lines != len(memory.Events) {
+		t.Errorf("Expected [%d] lines Received [%d]", len(memory.Events), lines)
+	}
+}
+
+func TestFileSinkNaNIndicator(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "events.jsonl")
+	sink := NewFileSink(fileName, FormatEventJSON)
+	// Indicators are NaN until the EMA has warmed up
+	e := Event{Kind: "BUY", AssetName: "SINE", Indicators: []strategy.Indicator{{Name: "EMA", Value: math.NaN()}, {Name: "MACD", Value: 1.5}}}
+	if err := sink.Write(e); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `{"name":"EMA","value":null}`) {
+		t.Errorf("Expected a null EMA Received %s", b)
+	}
+	read := Event{}
+	if err := json.Unmarshal(b, &read); err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Indicators) != 2 || !math.IsNaN(read.Indicators[0].Value) || read.Indicators[1] != e.Indicators[1] {
+		t.Errorf("Expected indicators %v Received %v", e.Indicators, read.Indicators)
+	}
+}
+
+/*
+Cancels ctx on the first event it receives
+*/
+type cancelSink struct {
+	MemorySink
+	cancel context.CancelFunc
+}
+
+func (cs *cancelSink) Write(e Event) error {
+	cs.cancel()
+	return cs.MemorySink.Write(e)
+}
+
+func TestRunSimulationCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sink := &cancelSink{cancel: cancel}
+	sim := newTestSimulation(t, sink)
+	r := RunSimulation(ctx, &sim)
+	if !errors.Is(r.Err, context.Canceled) {
+		t.Fatalf("Expected the simulation to be cancelled Received %v", r.Err)
+	}
+	if r.ResultString != "" || len(r.Equity) == 0 || len(r.Equity) >= 500 || len(sink.Events) != 1 {
+		t.Errorf("Expected a partial result without a result string Received %d equity values, %d events and %q", len(r.Equity), len(sink.Events), r.ResultString)
+	}
+}
@@ -0,0 +1,96 @@
+package simulation
+
+import (
+	"bufio"
+	"os"
+)
+
+/*
+Destination of a simulation's events.
+Write errors are kept by the simulation and returned in Result.Err.
+*/
+type EventSink interface {
+	Write(e Event) error
+	Close() error
+}
+
+/*
+Buffered event log, the file is opened on the first event and flushed on Close.
+An existing file is truncated so a re-run job (e.g. after -resume) does not repeat the events of an earlier attempt.
+*/
+type FileSink struct {
+	fileName string
+	format   EventFormatter
+	f        *os.File
+	w        *bufio.Writer
+}
+
+func NewFileSink(fileName string, format EventFormatter) *FileSink {
+	return &FileSink{fileName: fileName, format: format}
+}
+
+func (fs *FileSink) Write(e Event) error {
+	if fs.w == nil {
+		f, err := os.OpenFile(fs.fileName, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		fs.f = f
+		fs.w = bufio.NewWriter(f)
+	}
+	line, err := fs.format(e)
+	if err != nil {
+		return err
+	}
+	_, err = fs.w.WriteString(line)
+	return err
+}
+
+func (fs *FileSink) Close() error {
+	if fs.f == nil {
+		return nil
+	}
+	err := fs.w.Flush()
+	if closeErr := fs.f.Close(); err == nil {
+		err = closeErr
+	}
+	fs.f = nil
+	fs.w = nil
+	return err
+}
+
+/*
+Keeps every event in memory
+*/
+type MemorySink struct {
+	Events []Event
+}
+
+func (ms *MemorySink) Write(e Event) error {
+	ms.Events = append(ms.Events, e)
+	return nil
+}
+
+func (ms *MemorySink) Close() error {
+	return nil
+}
+
+/*
+Discards every event (large sweeps where per simulation logs are not needed)
+*/
+type NopSink struct{}
+
+func (NopSink) Write(e Event) error {
+	return nil
+}
+
+func (NopSink) Close() error {
+	return nil
+}
+
+/*
+Replaces the simulation's event sink (a FileSink writing JSON Lines to logFile by default)
+*/
+func SetEventSink(s *Simulation, sink EventSink) {
+	s.events = sink
+}
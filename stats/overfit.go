@@ -0,0 +1,206 @@
+package stats
+
+import (
+	"errors"
+	"math"
+)
+
+/*
+Overfitting diagnostics (Bailey & Lopez de Prado):
+
+	DeflatedSharpe: probability the best Sharpe ratio is positive after accounting for the number of trials
+	PBO:            probability of backtest overfitting using combinatorially symmetric cross-validation (CSCV)
+
+Sharpe ratios used here are per period (not annualized).
+*/
+
+const eulerGamma = 0.5772156649015329
+
+/*
+Running sums of a block of returns, enough to combine blocks into a Sharpe ratio
+*/
+type Moments struct {
+	N     int
+	Sum   float64
+	SumSq float64
+}
+
+/*
+Splits returns into nBlocks contiguous blocks of (almost) equal size
+*/
+func BlockMoments(returns []float64, nBlocks int) []Moments {
+	blocks := make([]Moments, nBlocks)
+	for i, r := range returns {
+		b := i * nBlocks / len(returns)
+		blocks[b].N += 1
+		blocks[b].Sum += r
+		blocks[b].SumSq += r * r
+	}
+	return blocks
+}
+
+/*
+Per period Sharpe ratio of the combined blocks
+*/
+func CombinedSharpe(blocks []Moments) float64 {
+	n := 0
+	sum := 0.0
+	sumSq := 0.0
+	for _, b := range blocks {
+		n += b.N
+		sum += b.Sum
+		sumSq += b.SumSq
+	}
+	if n < 2 {
+		return 0.0
+	}
+	mean := sum / float64(n)
+	variance := (sumSq - float64(n)*mean*mean) / float64(n-1)
+	if variance <= 0.0 {
+		return 0.0
+	}
+	return mean / math.Sqrt(variance)
+}
+
+/*
+Probability of backtest overfitting.
+trials[i] holds the block moments of trial i, every trial must have the same (even) number of blocks.
+Returns the PBO and the logit of the out-of-sample relative rank for every combination.
+*/
+func PBO(trials [][]Moments) (float64, []float64, error) {
+	if len(trials) < 2 {
+		return 0.0, nil, errors.New("PBO requires at least 2 trials")
+	}
+	nBlocks := len(trials[0])
+	if nBlocks < 2 || nBlocks%2 != 0 {
+		return 0.0, nil, errors.New("PBO requires an even number of blocks")
+	}
+	for _, t := range trials {
+		if len(t) != nBlocks {
+			return 0.0, nil, errors.New("PBO requires the same number of blocks for every trial")
+		}
+	}
+	logits := []float64{}
+	inSample := make([]Moments, 0, nBlocks/2)
+	outOfSample := make([]Moments, 0, nBlocks/2)
+	for _, combo := range combinations(nBlocks, nBlocks/2) {
+		chosen := make([]bool, nBlocks)
+		for _, b := range combo {
+			chosen[b] = true
+		}
+		best := -1
+		bestIS := math.Inf(-1)
+		oos := make([]float64, len(trials))
+		for i, t := range trials {
+			inSample = inSample[:0]
+			outOfSample = outOfSample[:0]
+			for b, m := range t {
+				if chosen[b] {
+					inSample = append(inSample, m)
+				} else {
+					outOfSample = append(outOfSample, m)
+				}
+			}
+			is := CombinedSharpe(inSample)
+			if is > bestIS {
+				bestIS = is
+				best = i
+			}
+			oos[i] = CombinedSharpe(outOfSample)
+		}
+		// Mid-rank among ties so trials sharing a Sharpe ratio (e.g. 0 for parameter sets that never trade)
+		// do not push the selected trial to the top
+		below, ties := 0, 0
+		for _, sr := range oos {
+			if sr < oos[best] {
+				below += 1
+			} else if sr == oos[best] {
+				ties += 1
+			}
+		}
+		rank := float64(below) + float64(ties+1)/2
+		omega := rank / float64(len(trials)+1)
+		logits = append(logits, math.Log(omega/(1-omega)))
+	}
+	overfit := 0
+	for _, l := range logits {
+		if l <= 0.0 {
+			overfit += 1
+		}
+	}
+	return float64(overfit) / float64(len(logits)), logits, nil
+}
+
+/*
+Probability that the true Sharpe ratio of the selected trial is above the expected maximum of nTrials unskilled trials.
+
+	sharpe:         per period Sharpe ratio of the selected trial
+	sharpeVariance: variance of the per period Sharpe ratios across all trials
+	nObs:           number of returns used to compute sharpe
+	skew, kurtosis: of the selected trial's returns (kurtosis is not excess)
+*/
+func DeflatedSharpe(sharpe float64, sharpeVariance float64, nTrials int, nObs int, skew float64, kurtosis float64) float64 {
+	sr0 := 0.0
+	if nTrials > 1 {
+		n := float64(nTrials)
+		sr0 = math.Sqrt(sharpeVariance) * ((1-eulerGamma)*NormInv(1-1/n) + eulerGamma*NormInv(1-1/(n*math.E)))
+	}
+	denom := 1 - skew*sharpe + (kurtosis-1)/4*sharpe*sharpe
+	if denom <= 0.0 || nObs < 2 {
+		return 0.0
+	}
+	return NormCDF((sharpe - sr0) * math.Sqrt(float64(nObs-1)) / math.Sqrt(denom))
+}
+
+func NormCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+/*
+Inverse of the standard normal CDF (Acklam's rational approximation)
+*/
+func NormInv(p float64) float64 {
+	if p <= 0.0 {
+		return math.Inf(-1)
+	}
+	if p >= 1.0 {
+		return math.Inf(1)
+	}
+	a := []float64{-3.969683028665376e+01, 2.209460984245205e+02, -2.759285104469687e+02, 1.383577518672690e+02, -3.066479806614716e+01, 2.506628277459239e+00}
+	b := []float64{-5.447609879822406e+01, 1.615858368580409e+02, -1.556989798598866e+02, 6.680131188771972e+01, -1.328068155288572e+01}
+	c := []float64{-7.784894002430293e-03, -3.223964580411365e-01, -2.400758277161838e+00, -2.549732539343734e+00, 4.374664141464968e+00, 2.938163982698783e+00}
+	d := []float64{7.784695709041462e-03, 3.224671290700398e-01, 2.445134137142996e+00, 3.754408661907416e+00}
+	low := 0.02425
+	if p < low {
+		q := math.Sqrt(-2 * math.Log(p))
+		return (((((c[0]*q+c[1])*q+c[2])*q+c[3])*q+c[4])*q + c[5]) / ((((d[0]*q+d[1])*q+d[2])*q+d[3])*q + 1)
+	}
+	if p > 1-low {
+		q := math.Sqrt(-2 * math.Log(1-p))
+		return -(((((c[0]*q+c[1])*q+c[2])*q+c[3])*q+c[4])*q + c[5]) / ((((d[0]*q+d[1])*q+d[2])*q+d[3])*q + 1)
+	}
+	q := p - 0.5
+	r := q * q
+	return (((((a[0]*r+a[1])*r+a[2])*r+a[3])*r+a[4])*r + a[5]) * q / (((((b[0]*r+b[1])*r+b[2])*r+b[3])*r+b[4])*r + 1)
+}
+
+/*
+Every combination of k indices out of n
+*/
+func combinations(n int, k int) [][]int {
+	combos := [][]int{}
+	combo := make([]int, k)
+	var rec func(start int, depth int)
+	rec = func(start int, depth int) {
+		if depth == k {
+			combos = append(combos, append([]int{}, combo...))
+			return
+		}
+		for i := start; i <= n-(k-depth); i++ {
+			combo[depth] = i
+			rec(i+1, depth+1)
+		}
+	}
+	rec(0, 0)
+	return combos
+}
@@ -0,0 +1,110 @@
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormInv(t *testing.T) {
+	for _, test := range []struct {
+		p float64
+		x float64
+	}{
+		{0.001, -3.090232306167813},
+		{0.01, -2.3263478740408408},
+		{0.025, -1.9599639845400538},
+		{0.5, 0.0},
+		{0.975, 1.9599639845400536},
+		{0.999, 3.090232306167813},
+		{0.0, math.Inf(-1)},
+		{1.0, math.Inf(1)},
+	} {
+		x := NormInv(test.p)
+		if math.IsInf(test.x, 0) && x != test.x || !math.IsInf(test.x, 0) && math.Abs(x-test.x) > 1e-6 {
+			t.Errorf("NormInv(%g): expected [%g] Received [%g]", test.p, test.x, x)
+		}
+		if !math.IsInf(x, 0) && math.Abs(NormCDF(x)-test.p) > 1e-8 {
+			t.Errorf("NormCDF(NormInv(%g)): Received [%g]", test.p, NormCDF(x))
+		}
+	}
+}
+
+func TestDeflatedSharpe(t *testing.T) {
+	for _, test := range []struct {
+		name           string
+		sharpe         float64
+		sharpeVariance float64
+		nTrials        int
+		nObs           int
+		skew           float64
+		kurtosis       float64
+		dsr            float64
+	}{
+		{"Single trial", 0.1, 0.0, 1, 101, 0, 3, 0.8407413278013518},
+		{"Zero Sharpe", 0.0, 0.0, 1, 101, 0, 3, 0.5},
+		{"No dispersion across trials", 0.1, 0.0, 10, 101, 0, 3, 0.8407413278013518},
+		{"Many trials", 0.1, 0.0025, 100, 1001, 0, 3, 0.20133358751410918},
+		{"Fat left tail", 0.1, 0.0025, 100, 1001, -1, 10, 0.21422219954626093},
+		{"Below the expected maximum", 0.05, 0.0025, 100, 1001, 0, 3, 0.007790512592457499},
+		{"Single observation", 0.1, 0.0, 1, 1, 0, 3, 0.0},
+		{"Degenerate moments", 1.0, 0.0, 1, 101, 3, 1, 0.0},
+	} {
+		dsr := DeflatedSharpe(test.sharpe, test.sharpeVariance, test.nTrials, test.nObs, test.skew, test.kurtosis)
+		if math.Abs(dsr-test.dsr) > 1e-6 {
+			t.Errorf("%s: expected [%g] Received [%g]", test.name, test.dsr, dsr)
+		}
+	}
+}
+
+func TestPBO(t *testing.T) {
+	good := Moments{2, 0.06, 0.002}  // Returns 0.02, 0.04
+	bad := Moments{2, -0.02, 0.0004} // Returns -0.02, 0
+	okay := Moments{2, 0.02, 0.0004} // Returns 0, 0.02
+	flat := Moments{2, 0, 0}         // Returns 0, 0
+	for _, test := range []struct {
+		name   string
+		trials [][]Moments
+		pbo    float64
+		combos int
+	}{
+		// The best trial in sample is the best out of sample
+		{"Persistent", [][]Moments{{good, good, good, good}, {okay, okay, okay, okay}, {bad, bad, bad, bad}}, 0.0, 6},
+		// The best trial in sample is the worst out of sample
+		{"Reversing", [][]Moments{{good, bad}, {bad, good}}, 1.0, 2},
+		// Trials that never trade tie at a Sharpe of 0, the selected trial takes the middle rank of the tie
+		{"Tied", [][]Moments{{good, flat}, {flat, flat}, {flat, flat}, {flat, flat}, {flat, flat}}, 0.5, 2},
+	} {
+		pbo, logits, err := PBO(test.trials)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if pbo != test.pbo || len(logits) != test.combos {
+			t.Errorf("%s: expected PBO [%g] over [%d] combinations Received [%g] over [%d]", test.name, test.pbo, test.combos, pbo, len(logits))
+		}
+	}
+	for name, trials := range map[string][][]Moments{
+		"One trial":        {{good, bad}},
+		"Odd blocks":       {{good, bad, okay}, {bad, good, okay}},
+		"Different blocks": {{good, bad}, {bad, good, okay, okay}},
+	} {
+		if _, _, err := PBO(trials); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestCombinedSharpe(t *testing.T) {
+	returns := []float64{0.01, -0.02, 0.03, 0.005, 0.0, 0.012, -0.007}
+	blocks := BlockMoments(returns, 4)
+	n := 0
+	for _, b := range blocks {
+		n += b.N
+	}
+	if n != len(returns) {
+		t.Fatalf("Expected [%d] returns in the blocks Received [%d]", len(returns), n)
+	}
+	if sharpe, expected := CombinedSharpe(blocks), Sharpe(returns, 1); math.Abs(sharpe-expected) > 1e-12 {
+		t.Errorf("Expected [%g] Received [%g]", expected, sharpe)
+	}
+}
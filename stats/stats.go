@@ -0,0 +1,178 @@
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+/*
+PUBLIC FUNCTIONS:
+	Mean
+	StdDev
+	Returns
+	MaxDrawdown
+	Drawdowns
+	Sharpe
+	Percentile
+	Skew
+	Kurtosis
+*/
+
+func Mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0.0
+	}
+	sum := 0.0
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+/*
+Sample standard deviation
+*/
+func StdDev(xs []float64) float64 {
+	if len(xs) < 2 {
+		return 0.0
+	}
+	mean := Mean(xs)
+	sum := 0.0
+	for _, x := range xs {
+		sum += (x - mean) * (x - mean)
+	}
+	return math.Sqrt(sum / float64(len(xs)-1))
+}
+
+/*
+Simple returns between consecutive values of an equity curve
+*/
+func Returns(equity []float64) []float64 {
+	if len(equity) < 2 {
+		return []float64{}
+	}
+	returns := make([]float64, 0, len(equity)-1)
+	for i := 1; i < len(equity); i++ {
+		if equity[i-1] == 0.0 {
+			returns = append(returns, 0.0)
+			continue
+		}
+		returns = append(returns, equity[i]/equity[i-1]-1)
+	}
+	return returns
+}
+
+/*
+Largest peak to trough decline of an equity curve as a fraction of the peak
+*/
+func MaxDrawdown(equity []float64) float64 {
+	peak := math.Inf(-1)
+	maxDD := 0.0
+	for _, e := range equity {
+		if e > peak {
+			peak = e
+		}
+		if peak > 0.0 {
+			dd := (peak - e) / peak
+			if dd > maxDD {
+				maxDD = dd
+			}
+		}
+	}
+	return maxDD
+}
+
+/*
+Decline from the running peak at every point of an equity curve as a fraction of the peak
+*/
+func Drawdowns(equity []float64) []float64 {
+	drawdowns := make([]float64, len(equity))
+	peak := math.Inf(-1)
+	for i, e := range equity {
+		if e > peak {
+			peak = e
+		}
+		if peak > 0.0 {
+			drawdowns[i] = (peak - e) / peak
+		}
+	}
+	return drawdowns
+}
+
+/*
+Annualized Sharpe ratio (risk free rate of 0) given the number of return periods per year
+*/
+func Sharpe(returns []float64, periodsPerYear float64) float64 {
+	sd := StdDev(returns)
+	if sd == 0.0 {
+		return 0.0
+	}
+	return Mean(returns) / sd * math.Sqrt(periodsPerYear)
+}
+
+/*
+Percentile p (0-100) using linear interpolation between closest ranks
+*/
+func Percentile(xs []float64, p float64) float64 {
+	if len(xs) == 0 {
+		return math.NaN()
+	}
+	sorted := append([]float64{}, xs...)
+	sort.Float64s(sorted)
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower < 0 {
+		return sorted[0]
+	}
+	if upper >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lower] + (rank-float64(lower))*(sorted[upper]-sorted[lower])
+}
+
+/*
+Population skewness
+*/
+func Skew(xs []float64) float64 {
+	if len(xs) < 2 {
+		return 0.0
+	}
+	mean := Mean(xs)
+	m2 := 0.0
+	m3 := 0.0
+	for _, x := range xs {
+		d := x - mean
+		m2 += d * d
+		m3 += d * d * d
+	}
+	m2 /= float64(len(xs))
+	m3 /= float64(len(xs))
+	if m2 == 0.0 {
+		return 0.0
+	}
+	return m3 / math.Pow(m2, 1.5)
+}
+
+/*
+Population kurtosis (not excess, a normal distribution has kurtosis 3)
+*/
+func Kurtosis(xs []float64) float64 {
+	if len(xs) < 2 {
+		return 3.0
+	}
+	mean := Mean(xs)
+	m2 := 0.0
+	m4 := 0.0
+	for _, x := range xs {
+		d := x - mean
+		m2 += d * d
+		m4 += d * d * d * d
+	}
+	m2 /= float64(len(xs))
+	m4 /= float64(len(xs))
+	if m2 == 0.0 {
+		return 3.0
+	}
+	return m4 / (m2 * m2)
+}
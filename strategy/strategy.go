@@ -1,7 +1,9 @@
 package strategy
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
 
 	"github.com/go-gota/gota/dataframe"
 )
@@ -9,9 +11,18 @@ import (
 /*
 LOCAL VARS:
 	strategies
+	stratColumns
+	sellConditionColumns
+	stratIndicators
 PUBLIC FUNCTIONS:
 	IsBuy
+	IsSell
 	IsValidStrategy
+	IsValidSellCondition
+	RequiredColumns
+	GetIndicators
+	GetStratString
+	FormatIndicators
 PRIVATE FUNCTIONS:
 	isBuyMACD
 	isBuyMACDCHAI
@@ -20,6 +31,26 @@ PRIVATE FUNCTIONS:
 
 var strategies = []string{"MACD-CHAI", "MACD", "PSAR", "MACD-PSAR", "alt-MACD"}
 
+/*
+Columns read by each strategy and sell condition ("EMA" is the EMA_<n> column selected by SetStratParams)
+*/
+var stratColumns = map[string][]string{
+	"MACD-CHAI": {"Close", "EMA", "MACD", "SIGNAL", "CHAI"},
+	"MACD-PSAR": {"Close", "EMA", "MACD", "SIGNAL", "SAR"},
+	"PSAR":      {"Close", "EMA", "SAR"},
+	"MACD":      {"Close", "EMA", "MACD", "SIGNAL"},
+	"alt-MACD":  {"Close", "EMA", "MACD", "SIGNAL"},
+}
+
+var sellConditionColumns = map[int][]string{
+	1: {},
+	2: {"Close", "EMA"},
+	3: {"Close", "EMA"},
+	4: {"Close", "EMA", "MACD", "SIGNAL"},
+	5: {"Close", "EMA"},
+	6: {"dP"},
+}
+
 func IsBuy(strat string, dataFrame *dataframe.DataFrame) bool {
 	switch strat {
 	case "MACD-CHAI":
@@ -113,36 +144,82 @@ func sellCondition1(minReturn float64, currentReturn float64) bool {
 	return false
 }
 
+type Indicator struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
+type indicatorJSON struct {
+	Name  string   `json:"name"`
+	Value *float64 `json:"value"`
+}
+
+/*
+Indicators are NaN during warm-up (e.g. the first rows of an EMA) and JSON has no NaN,
+non-finite values are written as null and null is read back as NaN
+*/
+func (ind Indicator) MarshalJSON() ([]byte, error) {
+	v := indicatorJSON{Name: ind.Name}
+	if !math.IsNaN(ind.Value) && !math.IsInf(ind.Value, 0) {
+		v.Value = &ind.Value
+	}
+	return json.Marshal(v)
+}
+
+func (ind *Indicator) UnmarshalJSON(b []byte) error {
+	v := indicatorJSON{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	ind.Name = v.Name
+	ind.Value = math.NaN()
+	if v.Value != nil {
+		ind.Value = *v.Value
+	}
+	return nil
+}
+
+type indicatorColumn struct {
+	name   string // Name used when logging
+	column string // Column in the data
+}
+
+var stratIndicators = map[string][]indicatorColumn{
+	"MACD-CHAI": {{"EMA", "EMA"}, {"MACD", "MACD"}, {"SIG", "SIGNAL"}, {"CHAI", "CHAI"}},
+	"MACD-PSAR": {{"EMA", "EMA"}, {"MACD", "MACD"}, {"SIG", "SIGNAL"}, {"PSAR", "SAR"}},
+	"PSAR":      {{"EMA", "EMA"}, {"PSAR", "SAR"}},
+	"MACD":      {{"EMA", "EMA"}, {"MACD", "MACD"}, {"SIG", "SIGNAL"}},
+	"alt-MACD":  {{"EMA", "EMA"}, {"MACD", "MACD"}, {"SIG", "SIGNAL"}},
+}
+
+/*
+Indicator values used by strat for the first row of dataFrame, in the order they are logged
+*/
+func GetIndicators(strat string, dataFrame *dataframe.DataFrame) []Indicator {
+	columns := stratIndicators[strat]
+	indicators := make([]Indicator, 0, len(columns))
+	for _, c := range columns {
+		indicators = append(indicators, Indicator{c.name, dataFrame.Select(c.column).Elem(0, 0).Float()})
+	}
+	return indicators
+}
+
 func GetStratString(strat string, dataFrame *dataframe.DataFrame) string {
+	return FormatIndicators(GetIndicators(strat, dataFrame))
+}
+
+/*
+Strat: [EMA: 1,MACD: 2,SIG: 3]
+*/
+func FormatIndicators(indicators []Indicator) string {
 	str := fmt.Sprintf("Strat: [")
-	switch strat {
-	case "MACD-CHAI":
-		ema := dataFrame.Select("EMA").Elem(0, 0).Float()
-		macd := dataFrame.Select("MACD").Elem(0, 0).Float()
-		signal := dataFrame.Select("SIGNAL").Elem(0, 0).Float()
-		chai := dataFrame.Select("CHAI").Elem(0, 0).Float()
-		str += fmt.Sprintf("EMA: %g,MACD: %g,SIG: %g,CHAI: %g]", ema, macd, signal, chai)
-	case "MACD-PSAR":
-		ema := dataFrame.Select("EMA").Elem(0, 0).Float()
-		macd := dataFrame.Select("MACD").Elem(0, 0).Float()
-		signal := dataFrame.Select("SIGNAL").Elem(0, 0).Float()
-		sar := dataFrame.Select("SAR").Elem(0, 0).Float()
-		str += fmt.Sprintf("EMA: %g,MACD: %g,SIG: %g,PSAR: %g]", ema, macd, signal, sar)
-	case "PSAR":
-		ema := dataFrame.Select("EMA").Elem(0, 0).Float()
-		sar := dataFrame.Select("SAR").Elem(0, 0).Float()
-		str += fmt.Sprintf("EMA: %g,PSAR: %g]", ema, sar)
-	case "MACD":
-		ema := dataFrame.Select("EMA").Elem(0, 0).Float()
-		macd := dataFrame.Select("MACD").Elem(0, 0).Float()
-		signal := dataFrame.Select("SIGNAL").Elem(0, 0).Float()
-		str += fmt.Sprintf("EMA: %g,MACD: %g,SIG: %g]", ema, macd, signal)
-	case "alt-MACD":
-		ema := dataFrame.Select("EMA").Elem(0, 0).Float()
-		macd := dataFrame.Select("MACD").Elem(0, 0).Float()
-		signal := dataFrame.Select("SIGNAL").Elem(0, 0).Float()
-		str += fmt.Sprintf("EMA: %g,MACD: %g,SIG: %g]", ema, macd, signal)
+	for i, ind := range indicators {
+		if i > 0 {
+			str += ","
+		}
+		str += fmt.Sprintf("%s: %g", ind.Name, ind.Value)
 	}
+	str += "]"
 	return str
 }
 
@@ -186,3 +263,23 @@ func IsValidStrategy(strategy string) bool {
 	}
 	return false
 }
+
+func IsValidSellCondition(sellCondition int) bool {
+	_, ok := sellConditionColumns[sellCondition]
+	return ok
+}
+
+/*
+Columns read by strat and sellCondition, without duplicates
+*/
+func RequiredColumns(strat string, sellCondition int) []string {
+	columns := []string{}
+	seen := map[string]bool{}
+	for _, col := range append(append([]string{}, stratColumns[strat]...), sellConditionColumns[sellCondition]...) {
+		if !seen[col] {
+			seen[col] = true
+			columns = append(columns, col)
+		}
+	}
+	return columns
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+
+	"Simulations_v5/results"
+)
+
+/*
+Leaderboard across one or more results files:
+
+	ranks parameter sets per asset and overall by a metric
+	compares every parameter set against buying and holding
+	writes <output_dir>/summary_<time>.csv and .json
+*/
+
+type summaryRow struct {
+	Rank         int     `json:"rank"`
+	Asset        string  `json:"asset"`
+	Strategy     string  `json:"strategy"`
+	EMA          int     `json:"ema"`
+	ReinvestPerc float64 `json:"reinvest_percentage"`
+	MinReturn    float64 `json:"min_return"`
+	PercentDrop  float64 `json:"percent_drop"`
+	BalanceTrip  float64 `json:"balance_tripwire"`
+	Metric       float64 `json:"metric"`
+	Profit       float64 `json:"profit"`
+	BuyHold      float64 `json:"buy_hold"`
+	Excess       float64 `json:"excess"`
+	FinalValue   float64 `json:"final_value"`
+	Transactions int     `json:"transactions"`
+	ResultsFile  string  `json:"results_file"`
+}
+
+type summary struct {
+	Metric  string                  `json:"metric"`
+	Files   []string                `json:"files"`
+	Assets  map[string][]summaryRow `json:"assets"`
+	Overall []summaryRow            `json:"overall"`
+}
+
+func runSummarize(args []string) error {
+	flags := flag.NewFlagSet("summarize", flag.ContinueOnError)
+	metric := flags.String("metric", "profit", fmt.Sprintf("metric to rank by (%s)", strings.Join(results.Metrics, " ")))
+	top := flags.Int("top", 10, "number of parameter sets shown per asset and overall")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 {
+		return errors.New("Usage: summarize [-metric name] [-top n] <results.csv>...")
+	}
+	sum := summary{Metric: *metric, Files: flags.Args(), Assets: map[string][]summaryRow{}, Overall: []summaryRow{}}
+	for _, fileName := range flags.Args() {
+		records, err := results.Load(fileName)
+		if err != nil {
+			return err
+		}
+		investment := getResultsInvestment(fileName)
+		for _, r := range records {
+			value, err := r.Metric(*metric, investment)
+			if err != nil {
+				return err
+			}
+			row := summaryRow{
+				Asset:        r.Asset,
+				Strategy:     r.Strategy,
+				EMA:          r.EMA,
+				ReinvestPerc: r.ReinvestPerc,
+				MinReturn:    r.MinReturn,
+				PercentDrop:  r.PercentDrop,
+				BalanceTrip:  r.BalanceTrip,
+				Metric:       value,
+				Profit:       r.Profit(investment),
+				BuyHold:      r.BuyHold,
+				Excess:       r.Profit(investment) - r.BuyHold,
+				FinalValue:   r.FinalValue,
+				Transactions: r.NumTransactions,
+				ResultsFile:  fileName,
+			}
+			sum.Assets[r.Asset] = append(sum.Assets[r.Asset], row)
+			sum.Overall = append(sum.Overall, row)
+		}
+	}
+	assetNames := []string{}
+	for asset := range sum.Assets {
+		rankRows(sum.Assets[asset], *metric)
+		assetNames = append(assetNames, asset)
+	}
+	sort.Strings(assetNames)
+	rankRows(sum.Overall, *metric)
+	for _, asset := range assetNames {
+		rows := sum.Assets[asset]
+		beat := 0
+		for _, row := range rows {
+			if row.Excess > 0 {
+				beat += 1
+			}
+		}
+		color.Cyan("\n%s: %d parameter sets, %d beat buy & hold (%g)", asset, len(rows), beat, rows[0].BuyHold)
+		printSummaryTable(rows, *top)
+	}
+	color.Cyan("\nOverall: %d parameter sets", len(sum.Overall))
+	printSummaryTable(sum.Overall, *top)
+	if _, err := os.Stat(outputDir); os.IsNotExist(err) {
+		err := os.MkdirAll(outputDir, 0755)
+		if err != nil {
+			return err
+		}
+	}
+	name := fmt.Sprintf("%s/summary_%s", outputDir, getRunName(time.Now()))
+	if err := os.WriteFile(name+".csv", []byte(getSummaryCSV(sum)), 0644); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(sum, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(name+".json", data, 0644); err != nil {
+		return err
+	}
+	color.Cyan("\nSummary written to %s.csv and %s.json", name, name)
+	return nil
+}
+
+/*
+Sorts rows best first, lower is better for tax, fees and transactions (see results.Better)
+*/
+func rankRows(rows []summaryRow, metric string) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		return results.Better(metric, rows[i].Metric, rows[j].Metric)
+	})
+	for i := range rows {
+		rows[i].Rank = i + 1
+	}
+}
+
+/*
+Rows beating buy & hold are green, the rest are red
+*/
+func printSummaryTable(rows []summaryRow, top int) {
+	header := fmt.Sprintf("%-5s %-8s %-10s %-4s %-8s %-9s %-8s %-8s %12s %12s %12s %6s", "Rank", "Asset", "Strategy", "EMA", "Reinvest", "MinReturn", "PctDrop", "BalTrip", "Metric", "Profit", "Excess", "Trans")
+	color.New(color.Bold).Println(header)
+	for i, row := range rows {
+		if i >= top {
+			break
+		}
+		line := fmt.Sprintf("%-5d %-8s %-10s %-4d %-8g %-9g %-8g %-8g %12.2f %12.2f %12.2f %6d", row.Rank, row.Asset, row.Strategy, row.EMA, row.ReinvestPerc, row.MinReturn, row.PercentDrop, row.BalanceTrip, row.Metric, row.Profit, row.Excess, row.Transactions)
+		if row.Excess > 0 {
+			color.Green("%s", line)
+		} else {
+			color.Red("%s", line)
+		}
+	}
+}
+
+func getSummaryCSV(sum summary) string {
+	var b strings.Builder
+	b.WriteString("Scope,Rank,Asset,Strategy,EMA,Reinvest,MinReturn,PercentDrop,BalanceTrip,Metric,Profit,BuyHold,Excess,FinalValue,Transactions,ResultsFile\n")
+	scopes := []string{}
+	for asset := range sum.Assets {
+		scopes = append(scopes, asset)
+	}
+	sort.Strings(scopes)
+	write := func(scope string, rows []summaryRow) {
+		for _, row := range rows {
+			fmt.Fprintf(&b, "%s,%d,%s,%s,%d,%g,%g,%g,%g,", scope, row.Rank, row.Asset, row.Strategy, row.EMA, row.ReinvestPerc, row.MinReturn, row.PercentDrop, row.BalanceTrip)
+			fmt.Fprintf(&b, "%g,%g,%g,%g,%g,%d,%s\n", row.Metric, row.Profit, row.BuyHold, row.Excess, row.FinalValue, row.Transactions, row.ResultsFile)
+		}
+	}
+	for _, asset := range scopes {
+		write(asset, sum.Assets[asset])
+	}
+	write("Overall", sum.Overall)
+	return b.String()
+}
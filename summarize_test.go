@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"Simulations_v5/results"
+)
+
+func TestRankRows(t *testing.T) {
+	for metric, expected := range map[string]string{"profit": "[3 2 1]", "fees": "[1 2 3]", "transactions": "[1 2 3]"} {
+		rows := []summaryRow{{Metric: 2}, {Metric: 1}, {Metric: 3}}
+		rankRows(rows, metric)
+		values := []float64{}
+		for i, row := range rows {
+			values = append(values, row.Metric)
+			if row.Rank != i+1 {
+				t.Errorf("%s: expected rank [%d] Received [%d]", metric, i+1, row.Rank)
+			}
+		}
+		if fmt.Sprint(values) != expected {
+			t.Errorf("%s: expected %s Received %v", metric, expected, values)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	confFile := writeTestConfig(t, "")
+	runName, err := sweep(context.Background(), confFile, "", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resultsFile := fmt.Sprintf("%s/SYNTH/%s.csv", outputDir, runName)
+	records, err := results.Load(resultsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A fresh output directory, the summary creates it
+	outputDir = filepath.Join(t.TempDir(), "summary")
+	if err := runSummarize([]string{"-metric", "fees", resultsFile}); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(outputDir + "/summary_*.json")
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one summary Received %v %v", files, err)
+	}
+	b, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := summary{}
+	if err := json.Unmarshal(b, &sum); err != nil {
+		t.Fatal(err)
+	}
+	if len(sum.Overall) != len(records) || len(sum.Assets["SYNTH"]) != len(records) {
+		t.Fatalf("Expected [%d] rows Received [%d] overall and [%d] for SYNTH", len(records), len(sum.Overall), len(sum.Assets["SYNTH"]))
+	}
+	buyHold := records[0].BuyHold
+	for i, row := range sum.Overall {
+		if i > 0 && row.Metric < sum.Overall[i-1].Metric {
+			t.Errorf("Rank %d: fees [%g] ranked after higher fees [%g]", row.Rank, row.Metric, sum.Overall[i-1].Metric)
+		}
+		if row.Rank != i+1 || row.BuyHold != buyHold || row.Excess != row.Profit-buyHold || row.Profit != row.FinalValue+findRecord(t, records, row).Revenue-investmentAMT {
+			t.Errorf("Unexpected row %+v (buy & hold %g)", row, buyHold)
+		}
+	}
+	f, err := os.Open(files[0][:len(files[0])-len(".json")] + ".csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lines, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 1+2*len(records) {
+		t.Fatalf("Expected [%d] CSV lines Received [%d]", 1+2*len(records), len(lines))
+	}
+	for i, row := range sum.Overall {
+		line := lines[1+len(records)+i]
+		if line[0] != "Overall" || line[1] != strconv.Itoa(row.Rank) || line[9] != strconv.FormatFloat(row.Metric, 'g', -1, 64) || line[12] != strconv.FormatFloat(row.Excess, 'g', -1, 64) {
+			t.Errorf("CSV line %v does not match %+v", line, row)
+		}
+	}
+}
+
+func findRecord(t *testing.T, records []results.Record, row summaryRow) results.Record {
+	t.Helper()
+	for _, r := range records {
+		if r.MinReturn == row.MinReturn && r.PercentDrop == row.PercentDrop {
+			return r
+		}
+	}
+	t.Fatalf("No record for %+v", row)
+	return results.Record{}
+}
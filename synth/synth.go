@@ -0,0 +1,151 @@
+package synth
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"Simulations_v5/data"
+)
+
+/*
+Synthetic price series with known behavior:
+
+	gbm:    geometric Brownian motion (trending)
+	ou:     Ornstein-Uhlenbeck on the log price, reverts to Mean (ranging)
+	regime: geometric Brownian motion switching between a bull (Drift, Volatility) and a bear (BearDrift, BearVolatility) regime
+	jump:   Merton jump-diffusion, geometric Brownian motion with normally distributed log jumps
+
+Rates (drift, volatility, mean reversion, switches and jumps) are annualized. Each bar is simulated in
+substeps, Open/High/Low/Close are the first, highest, lowest and last price of the bar.
+*/
+
+var Models = []string{"gbm", "ou", "regime", "jump"}
+
+const substeps = 8
+
+type Params struct {
+	StartPrice     float64 // Price at the start of the first bar
+	Drift          float64 // Expected return per year, log returns drift at Drift - Volatility²/2 (bull regime for regime)
+	Volatility     float64 // Standard deviation of log returns per year (bull regime for regime)
+	MeanReversion  float64 // ou: speed of reversion to Mean per year
+	Mean           float64 // ou: long run price
+	BearDrift      float64 // regime: drift of the bear regime
+	BearVolatility float64 // regime: volatility of the bear regime
+	SwitchRate     float64 // regime: expected regime switches per year
+	JumpIntensity  float64 // jump: expected jumps per year
+	JumpMean       float64 // jump: mean log jump size
+	JumpStd        float64 // jump: standard deviation of the log jump size
+}
+
+type Model interface {
+	Step(rng *rand.Rand, price float64, dt float64) float64
+}
+
+type GBM struct {
+	Drift      float64
+	Volatility float64
+}
+
+type OU struct {
+	MeanReversion float64
+	Mean          float64
+	Volatility    float64
+}
+
+type RegimeSwitching struct {
+	Bull       GBM
+	Bear       GBM
+	SwitchRate float64
+	bear       bool
+}
+
+type JumpDiffusion struct {
+	GBM
+	Intensity float64
+	JumpMean  float64
+	JumpStd   float64
+}
+
+func NewModel(name string, p Params) (Model, error) {
+	switch name {
+	case "gbm":
+		return &GBM{p.Drift, p.Volatility}, nil
+	case "ou":
+		if p.Mean <= 0.0 {
+			return nil, fmt.Errorf("Model [ou] requires a positive mean")
+		}
+		return &OU{p.MeanReversion, p.Mean, p.Volatility}, nil
+	case "regime":
+		return &RegimeSwitching{Bull: GBM{p.Drift, p.Volatility}, Bear: GBM{p.BearDrift, p.BearVolatility}, SwitchRate: p.SwitchRate}, nil
+	case "jump":
+		return &JumpDiffusion{GBM{p.Drift, p.Volatility}, p.JumpIntensity, p.JumpMean, p.JumpStd}, nil
+	}
+	return nil, fmt.Errorf("Invalid model [%s] (gbm, ou, regime or jump)", name)
+}
+
+func (m *GBM) Step(rng *rand.Rand, price float64, dt float64) float64 {
+	return price * math.Exp((m.Drift-m.Volatility*m.Volatility/2)*dt+m.Volatility*math.Sqrt(dt)*rng.NormFloat64())
+}
+
+func (m *OU) Step(rng *rand.Rand, price float64, dt float64) float64 {
+	x := math.Log(price)
+	x += m.MeanReversion*(math.Log(m.Mean)-x)*dt + m.Volatility*math.Sqrt(dt)*rng.NormFloat64()
+	return math.Exp(x)
+}
+
+func (m *RegimeSwitching) Step(rng *rand.Rand, price float64, dt float64) float64 {
+	if rng.Float64() < 1-math.Exp(-m.SwitchRate*dt) {
+		m.bear = !m.bear
+	}
+	if m.bear {
+		return m.Bear.Step(rng, price, dt)
+	}
+	return m.Bull.Step(rng, price, dt)
+}
+
+/*
+Drift is compensated for the expected jump so Drift remains the expected return
+*/
+func (m *JumpDiffusion) Step(rng *rand.Rand, price float64, dt float64) float64 {
+	compensation := m.Intensity * (math.Exp(m.JumpMean+m.JumpStd*m.JumpStd/2) - 1)
+	gbm := GBM{m.Drift - compensation, m.Volatility}
+	price = gbm.Step(rng, price, dt)
+	for jumps := poisson(rng, m.Intensity*dt); jumps > 0; jumps-- {
+		price *= math.Exp(m.JumpMean + m.JumpStd*rng.NormFloat64())
+	}
+	return price
+}
+
+func poisson(rng *rand.Rand, lambda float64) int {
+	limit := math.Exp(-lambda)
+	n := 0
+	for p := rng.Float64(); p > limit; p *= rng.Float64() {
+		n += 1
+	}
+	return n
+}
+
+/*
+Generates a bar at each of dates (Unix seconds), the same seed always generates the same bars.
+Each bar is 1/barsPerYear of a year (see calendar.BarsPerYear) so Drift and Volatility are per trading year.
+Volume grows with the size of the bar's move.
+*/
+func Generate(model Model, startPrice float64, seed int64, dates []int64, barsPerYear float64) []data.Bar {
+	rng := rand.New(rand.NewSource(seed))
+	dt := 1 / barsPerYear / substeps
+	bars := make([]data.Bar, 0, len(dates))
+	price := startPrice
+	for _, date := range dates {
+		bar := data.Bar{Date: date, Open: price, High: price, Low: price}
+		for s := 0; s < substeps; s++ {
+			price = model.Step(rng, price, dt)
+			bar.High = math.Max(bar.High, price)
+			bar.Low = math.Min(bar.Low, price)
+		}
+		bar.Close = price
+		bar.Volume = 100 * (1 + math.Abs(rng.NormFloat64())) * (1 + 100*math.Abs(math.Log(bar.Close/bar.Open)))
+		bars = append(bars, bar)
+	}
+	return bars
+}
@@ -0,0 +1,26 @@
+package synth
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestGBMDrift(t *testing.T) {
+	m := &GBM{Drift: 0.1, Volatility: 0.5}
+	rng := rand.New(rand.NewSource(1))
+	n := 200000
+	sumPrice, sumLog := 0.0, 0.0
+	for i := 0; i < n; i++ {
+		price := m.Step(rng, 100, 1)
+		sumPrice += price
+		sumLog += math.Log(price / 100)
+	}
+	// The price grows at Drift, log returns at Drift - Volatility²/2
+	if mean := sumPrice / float64(n); math.Abs(mean-100*math.Exp(0.1)) > 1 {
+		t.Errorf("Expected mean price [%g] Received [%g]", 100*math.Exp(0.1), mean)
+	}
+	if mean := sumLog / float64(n); math.Abs(mean-(0.1-0.125)) > 0.005 {
+		t.Errorf("Expected mean log return [%g] Received [%g]", 0.1-0.125, mean)
+	}
+}
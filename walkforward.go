@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/go-gota/gota/dataframe"
+	"gopkg.in/ini.v1"
+
+	"Simulations_v5/simulation"
+)
+
+/*
+Walk-forward optimization:
+
+	split the data into train/test windows (rolling or anchored)
+	run the [Parameters] grid on each training slice and keep the best
+	run the best parameter set on the following test slice
+	stitch the out-of-sample equity curves together
+*/
+
+type walkForwardParams struct {
+	trainBars int  // Number of bars in each training slice
+	testBars  int  // Number of bars in each test slice
+	anchored  bool // Training slices all start at the first bar instead of rolling forward
+}
+
+type wfWindow struct {
+	trainStart int
+	trainEnd   int // exclusive
+	testStart  int
+	testEnd    int // exclusive
+}
+
+func runWalkForward(ctx context.Context, confFile string) error {
+	params, err := getWalkForwardParams(confFile)
+	if err != nil {
+		return err
+	}
+	err = validateAssets()
+	if err != nil {
+		return err
+	}
+	color.Green("Running Walk-Forward Optimization")
+	start := time.Now()
+	runName := newRunName("WF_", start)
+	manifest, err := newManifest("walkforward", "WF_", confFile, runName, start, len(assets), nil)
+	if err != nil {
+		return err
+	}
+	// One job per asset
+	completed, failed := 0, 0
+	defer func() {
+		if err := manifest.finish(completed, failed, len(assets)-completed-failed); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}()
+	grid := getParamGrid()
+	for _, asset := range assets {
+		data, dataFile, hash, err := getData(asset, dataDir)
+		if err != nil {
+			fmt.Println(err)
+			failed += 1
+			continue
+		}
+		windows := getWindows(data.Nrow(), params)
+		if len(windows) == 0 {
+			fmt.Printf("[%s] Not enough data for a single walk-forward window\n", asset)
+			failed += 1
+			continue
+		}
+		final, err := walkForward(ctx, asset, data, dataFile, hash, grid, windows, runName)
+		if ctx.Err() != nil {
+			return err
+		}
+		if err != nil {
+			fmt.Println(err)
+			failed += 1
+			continue
+		}
+		color.Cyan("[%s] Out-of-sample value: %g (invested %g over %d windows)", asset, final, investmentAMT, len(windows))
+		completed += 1
+	}
+	s := fmt.Sprintf("Done in %v\nWalk-forward results can be found in %s", time.Since(start), outputDir)
+	color.Cyan(s)
+	return nil
+}
+
+func walkForward(ctx context.Context, asset string, data dataframe.DataFrame, dataFile string, hash string, grid []ParamSet, windows []wfWindow, runName string) (float64, error) {
+	dates, err := data.Col("Date").Int()
+	if err != nil {
+		return 0.0, err
+	}
+	outDir := fmt.Sprintf("%s/%s", outputDir, asset)
+	if _, err := os.Stat(outDir); os.IsNotExist(err) {
+		err := os.MkdirAll(outDir, 0755)
+		if err != nil {
+			return 0.0, err
+		}
+	}
+	var report, curve strings.Builder
+	report.WriteString("Window,TrainStart,TrainEnd,TestStart,TestEnd,Strategy,EMA,Reinvest,MinReturn,PercentDrop,BalanceTrip,InSample,OutOfSample\n")
+	curve.WriteString("Date,Window,Equity\n")
+	value := investmentAMT
+	for w, window := range windows {
+		train := sliceData(data, window.trainStart, window.trainEnd)
+		results := runParamSets(ctx, asset, train, dataFile, hash, grid)
+		if err := ctx.Err(); err != nil {
+			return 0.0, fmt.Errorf("[%s] Interrupted in window %d: %w", asset, w, err)
+		}
+		best := -1
+		for i, r := range results {
+			if r.Err != nil {
+				continue
+			}
+			if best < 0 || finalEquity(r) > finalEquity(results[best]) {
+				best = i
+			}
+		}
+		if best < 0 {
+			return 0.0, fmt.Errorf("[%s] Every parameter set failed on window %d", asset, w)
+		}
+		p := grid[best]
+		test := sliceData(data, window.testStart, window.testEnd)
+		r := runParamSet(ctx, asset, test, dataFile, hash, p)
+		if r.Err != nil {
+			return 0.0, r.Err
+		}
+		// Scale the test equity curve so it continues from where the previous window ended
+		for i, e := range r.Equity {
+			fmt.Fprintf(&curve, "%d,%d,%g\n", dates[window.testStart+i], w, value*e/investmentAMT)
+		}
+		value = value * finalEquity(r) / investmentAMT
+		fmt.Fprintf(&report, "%d,%d,%d,%d,%d,", w, dates[window.trainStart], dates[window.trainEnd-1], dates[window.testStart], dates[window.testEnd-1])
+		fmt.Fprintf(&report, "%s,%d,%g,%g,%g,%g,", p.Strategy, p.EMA, p.ReinvestPerc, p.MinReturn, p.PercentDrop, p.BalanceTrip)
+		fmt.Fprintf(&report, "%g,%g\n", finalEquity(results[best]), finalEquity(r))
+	}
+	fileName := fmt.Sprintf("%s/WF_%s.csv", outDir, runName)
+	if err := os.WriteFile(fileName, []byte(report.String()), 0644); err != nil {
+		return 0.0, err
+	}
+	fileName = fmt.Sprintf("%s/WF_%s_equity.csv", outDir, runName)
+	if err := os.WriteFile(fileName, []byte(curve.String()), 0644); err != nil {
+		return 0.0, err
+	}
+	return value, nil
+}
+
+func getWindows(nRows int, params walkForwardParams) []wfWindow {
+	windows := []wfWindow{}
+	for start := 0; start+params.trainBars+params.testBars <= nRows; start += params.testBars {
+		trainStart := start
+		if params.anchored {
+			trainStart = 0
+		}
+		windows = append(windows, wfWindow{
+			trainStart: trainStart,
+			trainEnd:   start + params.trainBars,
+			testStart:  start + params.trainBars,
+			testEnd:    start + params.trainBars + params.testBars,
+		})
+	}
+	return windows
+}
+
+/*
+Runs parameter sets concurrently (one worker per CPU), results are in the same order as params
+*/
+func runParamSets(ctx context.Context, asset string, data dataframe.DataFrame, dataFile string, hash string, params []ParamSet) []simulation.Result {
+	results := make([]simulation.Result, len(params))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = runParamSet(ctx, asset, data, dataFile, hash, params[i])
+			}
+		}()
+	}
+	for i := range params {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+/*
+Value of capital, reserves, asset and revenue at the last bar, FinalValue leaves out the revenue
+*/
+func finalEquity(r simulation.Result) float64 {
+	if len(r.Equity) == 0 {
+		return r.FinalValue
+	}
+	return r.Equity[len(r.Equity)-1]
+}
+
+func sliceData(data dataframe.DataFrame, start int, end int) dataframe.DataFrame {
+	indexes := make([]int, 0, end-start)
+	for i := start; i < end; i++ {
+		indexes = append(indexes, i)
+	}
+	return data.Subset(indexes)
+}
+
+func getWalkForwardParams(confFile string) (walkForwardParams, error) {
+	cfg, err := ini.Load(confFile)
+	if err != nil {
+		fmt.Printf("Failed to read %v\n", err)
+		return walkForwardParams{}, err
+	}
+	section := cfg.Section("WalkForward")
+	trainBars, err := section.Key("train_bars").Int()
+	if err != nil || trainBars < 1 {
+		return walkForwardParams{}, errors.New("Config file not configured for [train_bars]")
+	}
+	testBars, err := section.Key("test_bars").Int()
+	if err != nil || testBars < 1 {
+		return walkForwardParams{}, errors.New("Config file not configured for [test_bars]")
+	}
+	anchored := section.Key("anchored").MustBool(false)
+	return walkForwardParams{trainBars, testBars, anchored}, nil
+}
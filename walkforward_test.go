@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestGetWindows(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		nRows    int
+		params   walkForwardParams
+		expected []wfWindow
+	}{
+		{"rolling", 100, walkForwardParams{50, 20, false}, []wfWindow{{0, 50, 50, 70}, {20, 70, 70, 90}}},
+		{"anchored", 100, walkForwardParams{50, 20, true}, []wfWindow{{0, 50, 50, 70}, {0, 70, 70, 90}}},
+		{"exact fit", 70, walkForwardParams{50, 20, false}, []wfWindow{{0, 50, 50, 70}}},
+		{"too short", 69, walkForwardParams{50, 20, false}, []wfWindow{}},
+		{"empty", 0, walkForwardParams{50, 20, true}, []wfWindow{}},
+	} {
+		if windows := getWindows(test.nRows, test.params); fmt.Sprint(windows) != fmt.Sprint(test.expected) {
+			t.Errorf("%s: expected %v Received %v", test.name, test.expected, windows)
+		}
+	}
+}
+
+func readTestCSV(t *testing.T, fileName string) [][]string {
+	t.Helper()
+	f, err := os.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows[1:]
+}
+
+func TestWalkForwardStitchesEquity(t *testing.T) {
+	writeTestConfig(t, "")
+	data, dataFile, hash, err := getData("SYNTH", dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	windows := getWindows(data.Nrow(), walkForwardParams{600, 200, false})
+	if len(windows) < 2 {
+		t.Fatalf("Expected several windows Received %d", len(windows))
+	}
+	final, err := walkForward(context.Background(), "SYNTH", data, dataFile, hash, getParamGrid(), windows, "TEST")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := readTestCSV(t, fmt.Sprintf("%s/SYNTH/WF_TEST.csv", outputDir))
+	curve := readTestCSV(t, fmt.Sprintf("%s/SYNTH/WF_TEST_equity.csv", outputDir))
+	if len(report) != len(windows) || len(curve) != len(windows)*200 {
+		t.Fatalf("Expected [%d] windows and [%d] equity rows Received [%d] and [%d]", len(windows), len(windows)*200, len(report), len(curve))
+	}
+	// Every window ends at the value of the previous one scaled by its own out-of-sample return
+	value := investmentAMT
+	for w, row := range report {
+		oos, _ := strconv.ParseFloat(row[len(row)-1], 64)
+		value = value * oos / investmentAMT
+		last, _ := strconv.ParseFloat(curve[(w+1)*200-1][2], 64)
+		if math.Abs(last-value) > 1e-6*value {
+			t.Errorf("Window %d: expected equity [%g] at its last bar Received [%g]", w, value, last)
+		}
+	}
+	if math.Abs(final-value) > 1e-6*value {
+		t.Errorf("Expected final value [%g] Received [%g]", value, final)
+	}
+}
+
+func TestWalkForwardCancelled(t *testing.T) {
+	writeTestConfig(t, "")
+	data, dataFile, hash, err := getData("SYNTH", dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	windows := getWindows(data.Nrow(), walkForwardParams{600, 200, false})
+	if _, err := walkForward(ctx, "SYNTH", data, dataFile, hash, getParamGrid(), windows, "TEST"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the walk-forward to be cancelled Received %v", err)
+	}
+}